@@ -0,0 +1,847 @@
+// Package bench runs read benchmarks against a Cassandra table: Run drives
+// a Querier with the settings in a Config and returns the Result, and
+// RunBenchmark does the same against a gocql session.
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// selectTemplate is the point read issued for every key; the first %s is
+// the selection, the second the table.
+const selectTemplate = "SELECT %s FROM %s WHERE eqp_model = ? AND job_id = ? AND strtgy_name = ?"
+
+// partitionTemplate is the -partition-only read, which binds only the
+// partition key and returns every clustering row in the partition.
+const partitionTemplate = "SELECT %s FROM %s WHERE eqp_model = ?"
+
+// inListTemplate is the -in-list read, which binds the partition key, the
+// first clustering column and a list of strtgy_name values.
+const inListTemplate = "SELECT %s FROM %s WHERE eqp_model = ? AND job_id = ? AND strtgy_name IN ?"
+
+// pointReadStmt returns the read cfg issues against table. It selects
+// eqp_model, or WRITETIME and TTL of -metadata-column. -bucket-column adds
+// a bind marker for the key's time bucket. The -count-only variant adds
+// LIMIT 1 so the coordinator stops at the first row. -in-list selects
+// several clustering rows of a partition with IN. -order-by adds an
+// ORDER BY clause and -limit a LIMIT bind marker, for -partition-only
+// clustering-range reads.
+func pointReadStmt(table string, cfg Config) string {
+	template := selectTemplate
+	if cfg.PartitionOnly {
+		template = partitionTemplate
+	} else if cfg.InList > 0 {
+		template = inListTemplate
+	}
+	selection := "eqp_model"
+	if c := cfg.MetadataColumn; c != "" {
+		selection = "WRITETIME(" + c + "), TTL(" + c + ")"
+	}
+	stmt := fmt.Sprintf(template, selection, table)
+	if cfg.BucketColumn != "" {
+		stmt += " AND " + cfg.BucketColumn + " = ?"
+	}
+	if cfg.OrderBy != "" {
+		stmt += " ORDER BY " + cfg.OrderBy
+	}
+	if cfg.CountOnly {
+		stmt += " LIMIT 1"
+	} else if cfg.Limit > 0 {
+		stmt += " LIMIT ?"
+	}
+	return stmt
+}
+
+// unpreparedMarker is prepended to statements that must not be prepared.
+// gocql prepares every statement that starts with a DML keyword, so a leading
+// comment is what makes it send a plain QUERY frame instead.
+const unpreparedMarker = "/* unprepared */ "
+
+// unpreparedStmt renders stmt with the bind values inlined as CQL literals.
+// Unprepared QUERY frames carry no bound values in gocql, so the values have
+// to be part of the statement text.
+func unpreparedStmt(stmt string, values []interface{}) string {
+	for _, v := range values {
+		stmt = strings.Replace(stmt, "?", cqlLiteral(v), 1)
+	}
+	return unpreparedMarker + stmt
+}
+
+// cqlLiteral renders a bind value as a CQL literal. Strings and timestamps
+// are quoted; numbers, booleans and UUIDs are written bare; an -in-list
+// list becomes a parenthesized IN list.
+func cqlLiteral(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return cqlQuote(v)
+	case time.Time:
+		return cqlQuote(v.Format(time.RFC3339Nano))
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = cqlLiteral(item)
+		}
+		return "(" + strings.Join(items, ", ") + ")"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// cqlQuote returns s as a single-quoted CQL string literal.
+func cqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// nullable wraps a scan destination so a NULL cell can be told apart from
+// an empty or zero value without the per-row allocation gocql makes when
+// scanning into a pointer to a pointer.
+type nullable struct {
+	Value interface{}
+	null  bool
+}
+
+func (n *nullable) UnmarshalCQL(info gocql.TypeInfo, data []byte) error {
+	n.null = data == nil
+	return gocql.Unmarshal(info, data, n.Value)
+}
+
+// isNull reports whether the scan destination d, a *nullable, held NULL.
+func isNull(d interface{}) bool {
+	n, ok := d.(*nullable)
+	return ok && n.null
+}
+
+// scanTarget holds a worker's scan destinations: one per column the query
+// actually returned, typed from the result metadata and wrapped in
+// nullable, so any SELECT can be scanned without a "not enough columns"
+// error or silently under-scanning, and NULLs stay visible.
+// The destinations are reused while the columns keep the same native
+// types, so a steady workload allocates no scan targets per query.
+type scanTarget struct {
+	types []gocql.TypeInfo
+	dest  []interface{}
+}
+
+// forIter returns the scan destinations for iter's columns.
+func (s *scanTarget) forIter(iter RowIter) []interface{} {
+	columns := iter.Columns()
+	if !s.matches(columns) {
+		s.types = s.types[:0]
+		s.dest = make([]interface{}, len(columns))
+		for i, col := range columns {
+			s.types = append(s.types, col.TypeInfo)
+			s.dest[i] = &nullable{Value: scanValue(col.TypeInfo)}
+		}
+	}
+	return s.dest
+}
+
+// scanValue returns the destination for a column of type info. Text cells
+// scan into a []byte, which gocql refills in place, instead of a string it
+// would allocate per row; nothing reads the text, only its length.
+func scanValue(info gocql.TypeInfo) interface{} {
+	switch info.Type() {
+	case gocql.TypeAscii, gocql.TypeText, gocql.TypeVarchar:
+		return new([]byte)
+	}
+	return info.New()
+}
+
+// matches reports whether columns have the types the destinations were
+// built for. Only native types are compared; collection and UDT columns
+// always rebuild their targets.
+func (s *scanTarget) matches(columns []gocql.ColumnInfo) bool {
+	if s.dest == nil || len(columns) != len(s.types) {
+		return false
+	}
+	for i, col := range columns {
+		native, ok := col.TypeInfo.(gocql.NativeType)
+		if !ok {
+			return false
+		}
+		if prev, ok := s.types[i].(gocql.NativeType); !ok || prev != native {
+			return false
+		}
+	}
+	return true
+}
+
+// LeanPointRead reports whether cfg is the default point read: one prepared
+// statement binding exactly the three key fields. Workers then bind from a
+// reused args slice and the keys are boxed once at load, so binding a query
+// allocates nothing; TestLeanPointReadDoesNotAllocate keeps it that way.
+func (cfg Config) LeanPointRead() bool {
+	return !cfg.PartitionOnly && !cfg.CountOnly && len(cfg.Buckets) == 0 && cfg.Limit == 0 &&
+		!cfg.NoPrepare && cfg.SplitPrepare == 0
+}
+
+// thinkDelay returns how long a worker should idle before its next query.
+// The delay is drawn uniformly from [think-time - jitter, think-time + jitter]
+// and clamped at zero. Each worker passes its own rand source so workers never
+// contend on the global one.
+//
+// Think time turns each worker into a simulated client that idles between
+// requests, so throughput is bounded by roughly
+// concurrency / (query latency + think time) rather than by the cluster.
+// The -rate limiter bounds throughput from the dispatch side instead, so
+// combining the two mostly means the lower bound wins: think time only has a
+// visible effect when it makes the workers slower than their share of -rate.
+func thinkDelay(rng *rand.Rand, thinkTime, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return thinkTime
+	}
+	d := thinkTime - jitter + time.Duration(rng.Int63n(int64(2*jitter)+1))
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// benchmark holds everything a pass over the workload needs. Cancelling ctx
+// stops the current pass early; queries in flight are cancelled with it.
+type benchmark struct {
+	ctx     context.Context
+	cfg     Config
+	querier Querier
+	keys    []QueryKey
+	tracer  gocql.Tracer
+	targets []Target
+
+	// abort cancels ctx with a cause; -fail-fast uses it on the first
+	// query error.
+	abort context.CancelCauseFunc
+
+	// probe watches the first queries of the run for -run-retries. It is
+	// shared by every pass, so warm-up queries count.
+	probe *readinessProbe
+
+	// writes records the -write-mix writes of every pass for the read-back
+	// check at the end of the run.
+	writes *writeLedger
+
+	// timeline collects the per-second -timeline-file counts of every
+	// measured pass.
+	timeline *timeline
+}
+
+// readinessProbe tracks whether the first want queries of a run all
+// failed. Any success before then disarms it.
+type readinessProbe struct {
+	mu     sync.Mutex
+	want   int
+	failed int
+	done   bool
+}
+
+// observe records one query and reports whether it was the last of want
+// consecutive leading failures.
+func (p *readinessProbe) observe(err error) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done {
+		return false
+	}
+	if err == nil {
+		p.done = true
+		return false
+	}
+	p.failed++
+	if p.failed >= p.want {
+		p.done = true
+		return true
+	}
+	return false
+}
+
+// runOptions selects how a single pass executes its queries.
+type runOptions struct {
+	// Unprepared sends every query as an unprepared statement with the key
+	// values inlined.
+	Unprepared bool
+
+	// Queries and Rate override cfg.NumQueries and cfg.Rate when non-zero.
+	Queries int
+	Rate    float64
+
+	// Schedule gives each query's dispatch offset from the start of the
+	// pass, divided by cfg.ReplaySpeed. It replaces -rate pacing.
+	Schedule []time.Duration
+
+	// Discard marks a pass whose results are thrown away (warm-up,
+	// priming); it reports no progress.
+	Discard bool
+
+	// Keys, when set, replaces b.keys: each key received is queried once
+	// and the pass ends when the channel is closed, however many queries
+	// that makes.
+	Keys <-chan QueryKey
+
+	// Insert writes every key with an INSERT instead of reading it, for a
+	// -phases write phase.
+	Insert bool
+
+	// ConsistencyMix overrides cfg.ConsistencyMix when set.
+	ConsistencyMix []ConsistencyShare
+}
+
+// job is one query handed to a worker.
+type job struct {
+	id  int
+	key QueryKey
+}
+
+// run executes cfg.NumQueries queries across cfg.Concurrency workers and
+// returns the summary of the pass.
+func (b *benchmark) run(opts runOptions) (result Result) {
+	cfg := b.cfg
+	if opts.Queries > 0 {
+		cfg.NumQueries = opts.Queries
+	}
+	if opts.Rate > 0 {
+		cfg.Rate = opts.Rate
+	}
+	if opts.ConsistencyMix != nil {
+		cfg.ConsistencyMix = opts.ConsistencyMix
+	}
+
+	targets := append([]Target(nil), b.targets...)
+	if len(targets) == 0 {
+		targets = []Target{{Name: defaultTable, Weight: 1}}
+	}
+	for i := range targets {
+		if opts.Insert {
+			targets[i].stmt = insertStmt(targets[i].Name, cfg)
+		} else {
+			targets[i].stmt = pointReadStmt(targets[i].Name, cfg)
+		}
+	}
+	picker := newTargetPicker(targets)
+	breakdown := len(b.targets) > 0
+
+	consistencyPicker := newConsistencyPicker(cfg.ConsistencyMix)
+	lean := cfg.LeanPointRead() && !opts.Unprepared
+
+	var buckets *bucketPicker
+	if len(cfg.Buckets) > 0 {
+		buckets = newBucketPicker(cfg.Buckets, cfg.BucketDecay)
+	}
+
+	var wg sync.WaitGroup
+	var stats runStats
+	var inFlight atomic.Int64
+	latencies := newLatencyCollector(cfg.ResultBuffer)
+
+	slowQuery := time.Duration(cfg.SlowQueryMs * float64(time.Millisecond))
+	slowLog := newLogThrottle(time.Second)
+
+	// Warm-up and priming passes stay off the -timeline-file.
+	var tl *timeline
+	if !opts.Discard {
+		tl = b.timeline
+	}
+	if tl != nil {
+		tl.begin()
+	}
+	var gcStart runtime.MemStats
+	runtime.ReadMemStats(&gcStart)
+	startTime := time.Now()
+	sampler := startInFlightSampler(&inFlight, 10*time.Millisecond, tl)
+
+	// partial summarizes the pass so far. avgInFlight is passed in because
+	// the sampler can only be read once, when the pass is over.
+	partial := func(avgInFlight float64) Result {
+		result := stats.result(cfg)
+		result.TotalSeconds = time.Since(startTime).Seconds()
+		if result.TotalSeconds > 0 {
+			result.QPS = float64(result.CompletedQueries) / result.TotalSeconds
+		}
+		if result.TotalSeconds > 0 && cfg.MeasureBytes {
+			result.BytesReadPerSecond = float64(result.BytesRead) / result.TotalSeconds
+			result.BytesWrittenPerSecond = float64(result.BytesWritten) / result.TotalSeconds
+		}
+		result.TargetRate = cfg.Rate
+		result.StoppedEarly = b.ctx.Err() != nil
+		result.AvgInFlight = avgInFlight
+		cycles, pause, longest := gcSince(&gcStart)
+		result.GCCycles = cycles
+		result.GCPauseMs = float64(pause) / float64(time.Millisecond)
+		result.GCMaxPauseMs = float64(longest) / float64(time.Millisecond)
+		latencies.Fill(&result)
+		result.latencyHist = latencies.Histogram()
+		result.stats = &stats
+		return result
+	}
+	snapshot := func() Result {
+		return partial(sampler.Average())
+	}
+
+	// Progress snapshots report the in-flight count at that moment.
+	stopProgress := func() {}
+	if cfg.ProgressInterval > 0 && !opts.Discard {
+		stopProgress = startProgress(cfg, func() Result {
+			r := partial(float64(inFlight.Load()))
+			r.Partial = true
+			return r
+		})
+	}
+
+	// A panic outside a query would otherwise discard everything collected
+	// so far, so it stops the run with ErrPanic and the caller reports the
+	// partial result like any other early stop.
+	crash := func(r any) {
+		cfg.logger().Printf("PANIC: %v\n%s", r, debug.Stack())
+		b.abort(fmt.Errorf("%w: %v", ErrPanic, r))
+	}
+
+	// dueTime is when query i should start under -rate or a replay schedule.
+	dueTime := func(i int) time.Time {
+		if opts.Schedule != nil {
+			return startTime.Add(time.Duration(float64(opts.Schedule[i]) / cfg.ReplaySpeed))
+		}
+		return startTime.Add(time.Duration(float64(i) / cfg.Rate * float64(time.Second)))
+	}
+
+	// Create a channel to send jobs to workers
+	jobBuffer := cfg.NumQueries
+	if opts.Keys != nil {
+		jobBuffer = cfg.Concurrency
+	}
+	jobs := make(chan job, jobBuffer)
+	var closeJobs sync.Once
+	submitted := 0
+
+	// finish lets the workers drain the jobs and summarizes the pass.
+	finish := func() Result {
+		closeJobs.Do(func() { close(jobs) })
+		wg.Wait()
+		stopProgress()
+		latencies.Close()
+		result := snapshot()
+		if opts.Keys != nil {
+			// The stream, not -queries, decided how many queries ran.
+			result.Queries = submitted
+		}
+		return result
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			crash(r)
+			result = finish()
+		}
+	}()
+
+	// Start a fixed number of worker goroutines
+	for w := 1; w <= cfg.Concurrency; w++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			// A panic outside a query is a bug in the worker itself.
+			defer func() {
+				if r := recover(); r != nil {
+					crash(r)
+				}
+			}()
+			rng := rand.New(rand.NewSource(cfg.Seed + int64(workerID)))
+			var labelBuf [4]label
+			firstQuery := true
+			scanColumns := 0
+			var scan scanTarget
+			// row is the -map-scan destination, cleared before every row
+			// because gocql scans into any entry it already holds.
+			var row map[string]interface{}
+			if cfg.MapScan {
+				row = make(map[string]interface{})
+			}
+			// args is reused for every lean point read; the query is done
+			// with its values before the next one is bound.
+			var args []interface{}
+			// runQuery runs one job. A panic in it (a malformed key, a bug in
+			// a query mode) fails that query only: it is counted and logged
+			// with its key, and the worker goes on with the next job.
+			runQuery := func(j job) {
+				started := time.Now()
+				// held is whether the query is counted in inFlight.
+				held := false
+				cancelBudget := context.CancelFunc(func() {})
+				defer func() {
+					r := recover()
+					if r == nil {
+						return
+					}
+					cancelBudget()
+					if held {
+						inFlight.Add(-1)
+					}
+					key := j.key
+					cfg.logger().Printf("PANIC in query %d with eqp_model=%q job_id=%q strtgy_name=%q: %v\n%s",
+						j.id, key.EqpModel, key.JobID, key.StrategyName, r, debug.Stack())
+					err := fmt.Errorf("%w: %v", errQueryPanic, r)
+					if cfg.FailFast {
+						b.abort(fmt.Errorf("%w: query %d with eqp_model=%q job_id=%q strtgy_name=%q panicked: %v",
+							ErrFailFast, j.id, key.EqpModel, key.JobID, key.StrategyName, r))
+					}
+					stats.recordPanic()
+					stats.record(false, err, time.Since(started))
+					if tl != nil {
+						tl.record(true)
+					}
+				}()
+				queryID, key := j.id, j.key
+				t := &targets[picker.pick(rng)]
+				stmt := t.stmt
+				labels := labelBuf[:0]
+				if breakdown {
+					labels = append(labels, label{"target", t.Name})
+				}
+
+				var bindStart time.Time
+				if cfg.PhaseTiming {
+					bindStart = time.Now()
+				}
+				var values []interface{}
+				if lean && key.typed != nil {
+					args = append(args[:0], key.typed...)
+					values = args
+				} else if lean {
+					args = append(args[:0], key.EqpModel, key.JobID, key.StrategyName)
+					values = args
+				} else {
+					values = key.bindValues()
+				}
+				if cfg.PartitionOnly && !opts.Insert {
+					values = values[:1]
+				}
+				var bucket string
+				if cfg.BucketColumn != "" {
+					bucket = key.Bucket
+					if bucket == "" && buckets != nil {
+						bucket = buckets.pick(rng)
+					}
+					values = append(values, bucket)
+					labels = append(labels, label{"bucket", bucket})
+				}
+				if cfg.Limit > 0 && !opts.Insert {
+					values = append(values, int32(cfg.Limit))
+				}
+
+				// -split-prepare puts each query in the prepared or the
+				// unprepared cohort at random.
+				unprepared := opts.Unprepared
+				if cfg.SplitPrepare > 0 {
+					unprepared = rng.Float64() >= cfg.SplitPrepare
+				}
+				// -write-mix replaces some reads with non-idempotent counter
+				// increments; reads are marked idempotent. A -phases insert
+				// is a write too, but an idempotent one.
+				write := opts.Insert || (b.writes != nil && rng.Float64() < cfg.WriteMix)
+				var query Statement
+				// written are the values a write sends, for -measure-bytes.
+				written := values
+				if write && !opts.Insert {
+					writeStmt, writeValues := b.writes.issue(writeKey(key))
+					written = writeValues
+					query = b.querier.Query(writeStmt, writeValues...).Idempotent(false)
+					labels = append(labels, label{"op", "write"})
+				} else {
+					if unprepared {
+						query = b.querier.Query(unpreparedStmt(stmt, values))
+					} else {
+						query = b.querier.Query(stmt, values...)
+					}
+					// gocql only retries idempotent queries (-num-retries).
+					if b.writes != nil || cfg.Cluster.NumRetries > 0 {
+						query = query.Idempotent(true)
+					}
+					if b.writes != nil {
+						labels = append(labels, label{"op", "read"})
+					}
+				}
+				if len(cfg.ConsistencyMix) > 0 {
+					level := cfg.ConsistencyMix[consistencyPicker.pick(rng)].Level
+					query = query.Consistency(level)
+					labels = append(labels, label{"consistency", level.String()})
+				}
+				var observer gocql.QueryObserver
+				if cfg.ReportDC {
+					observer = dcObserver{&stats}
+				}
+				if cfg.ReportHosts {
+					observer = hostObserver{stats: &stats, next: observer}
+				}
+				var pool *poolObserver
+				if cfg.PoolWait {
+					pool = &poolObserver{stats: &stats, next: observer}
+					observer = pool
+				}
+				if observer != nil {
+					query = query.Observer(observer)
+				}
+				if b.tracer != nil && rng.Float64() < cfg.TraceSample {
+					query = query.Trace(b.tracer)
+				}
+				// With -retry-budget every attempt shares one deadline, so
+				// retries cannot stretch a query past the budget.
+				budgetCtx := b.ctx
+				if cfg.RetryBudget > 0 {
+					budgetCtx, cancelBudget = context.WithTimeout(b.ctx, cfg.RetryBudget)
+				}
+				inFlight.Add(1)
+				held = true
+				queryStart := time.Now()
+				if opts.Schedule != nil {
+					stats.recordLag(queryStart.Sub(dueTime(queryID)))
+				} else if cfg.PoolWait && cfg.Rate > 0 {
+					stats.recordSlotWait(queryStart.Sub(dueTime(queryID)))
+				}
+				var scanStart time.Time
+				// execute runs q with -retries and reports whether it found
+				// a row.
+				execute := func(q Statement) (found bool, err error) {
+					for attempt := 0; ; attempt++ {
+						ctx, cancel := budgetCtx, context.CancelFunc(func() {})
+						if cfg.OpTimeout > 0 {
+							// A fresh context per attempt so the deadline covers
+							// this operation only, including any driver-level
+							// retries.
+							ctx, cancel = context.WithTimeout(budgetCtx, cfg.OpTimeout)
+						}
+						if pool != nil {
+							pool.submitted = time.Now()
+						}
+						iter := q.WithContext(ctx).Iter()
+						if cfg.PhaseTiming {
+							scanStart = time.Now()
+						}
+						if write {
+							// An update or insert returns no rows; it counts
+							// as a hit when it succeeds.
+						} else if cfg.CountOnly {
+							// Existence only: no scan target, so no per-row
+							// allocation on the client.
+							found = iter.NumRows() > 0
+						} else if cfg.MapScan {
+							// Keyed by column name, so any SELECT scans
+							// without knowing its columns up front.
+							rows := 0
+							for clear(row); iter.MapScan(row); clear(row) {
+								if rows == 0 && len(row) != scanColumns {
+									scanColumns = len(row)
+									stats.recordColumnNames(row)
+								}
+								rows++
+								if !cfg.PartitionOnly && cfg.InList == 0 {
+									break
+								}
+							}
+							found = rows > 0
+							if cfg.PartitionOnly || cfg.InList > 0 {
+								stats.recordRows(rows, cfg.Limit)
+							}
+						} else {
+							dest := scan.forIter(iter)
+							if len(dest) != scanColumns {
+								scanColumns = len(dest)
+								stats.recordColumns(scanColumns)
+							}
+							var bytesRead int64
+							// Without -metadata-column the first column is
+							// eqp_model, which a sound row never has NULL.
+							checkNull := cfg.MetadataColumn == ""
+							if cfg.PartitionOnly || cfg.InList > 0 {
+								rows, nullRows := 0, 0
+								for iter.Scan(dest...) {
+									rows++
+									if checkNull && isNull(dest[0]) {
+										nullRows++
+										stats.recordNullKey()
+									}
+									if cfg.MeasureBytes {
+										bytesRead += scannedBytes(dest)
+									}
+								}
+								found = rows > 0
+								if cfg.NullKeyMiss {
+									found = rows > nullRows
+								}
+								stats.recordRows(rows, cfg.Limit)
+							} else {
+								found = iter.Scan(dest...)
+								if found && checkNull && isNull(dest[0]) {
+									stats.recordNullKey()
+									found = !cfg.NullKeyMiss
+								}
+								if found && cfg.MeasureBytes {
+									bytesRead = scannedBytes(dest)
+								}
+								if found && cfg.MetadataColumn != "" {
+									recordMetadata(&stats, dest, cfg.ExpiryWindow)
+								}
+							}
+							if cfg.MeasureBytes {
+								stats.recordBytes(bytesRead)
+							}
+						}
+
+						err = iter.Close()
+						cancel()
+						if write {
+							found = err == nil
+							if found && cfg.MeasureBytes {
+								stats.recordBytesWritten(boundBytes(written))
+							}
+						}
+						// A write that timed out may still have been
+						// applied, so it is only retried with
+						// -retry-non-idempotent.
+						if err == nil || attempt >= cfg.Retries || budgetCtx.Err() != nil || (write && !opts.Insert && !cfg.RetryNonIdempotent) {
+							break
+						}
+						stats.recordRetry()
+					}
+					return found, err
+				}
+				var found bool
+				var err error
+				if cfg.FastPath {
+					// Try the cheap LOCAL_ONE read first and pay for QUORUM
+					// only when it fails (or, with -fallback-on-miss, finds
+					// nothing, which may mean a stale replica).
+					found, err = execute(query.Consistency(gocql.LocalOne))
+					if b.ctx.Err() == nil && (err != nil || (!found && cfg.FallbackOnMiss)) {
+						fallbackStart := time.Now()
+						found, err = execute(query.Consistency(gocql.Quorum))
+						stats.recordFallback(time.Since(fallbackStart))
+					} else if err == nil {
+						stats.recordFastPath()
+					}
+				} else {
+					found, err = execute(query)
+				}
+				latency := time.Since(queryStart)
+				if cfg.PhaseTiming {
+					stats.recordPhases(queryStart.Sub(bindStart), scanStart.Sub(queryStart), latency-scanStart.Sub(queryStart))
+				}
+				if err != nil && b.ctx.Err() != nil {
+					// Cut off by the run being stopped, not a real failure.
+					cancelBudget()
+					inFlight.Add(-1)
+					held = false
+					return
+				}
+				if err != nil && cfg.RetryBudget > 0 && errors.Is(budgetCtx.Err(), context.DeadlineExceeded) {
+					stats.recordBudgetExhausted()
+				}
+				if err != nil {
+					if problem := schemaProblem(err); problem != "" {
+						// Logging it per query would only repeat the same line.
+						b.abort(fmt.Errorf("%w: query %d against %s failed with %q; check -keyspace, -targets and the table's columns",
+							ErrSchema, queryID, t.Name, problem))
+					} else {
+						cfg.logger().Printf("Query %d failed: %v", queryID, err)
+					}
+					if cfg.FailFast {
+						b.abort(fmt.Errorf("%w: query %d against %s with eqp_model=%q job_id=%q strtgy_name=%q failed: %v",
+							ErrFailFast, queryID, t.Name, key.EqpModel, key.JobID, key.StrategyName, err))
+					}
+					if cfg.SampleErrors > 0 {
+						sampled := key
+						sampled.Bucket = bucket
+						stats.recordErrorSample(cfg.SampleErrors, t.Name, sampled, err)
+					}
+				}
+				if b.probe != nil && b.probe.observe(err) {
+					b.abort(fmt.Errorf("%w: the first %d queries all failed, last with: %v", ErrNotReady, cfg.RunRetryProbe, err))
+				}
+				stats.record(found, err, latency, labels...)
+				if cfg.MaxPartitions > 0 {
+					stats.recordPartition(key.EqpModel, bucket)
+				}
+				if tl != nil {
+					tl.record(err != nil)
+				}
+				if cfg.SplitPrepare > 0 {
+					stats.recordCohort(unprepared, err, latency)
+				}
+				if slowQuery > 0 && latency > slowQuery {
+					stats.recordSlow()
+					if ok, suppressed := slowLog.allow(); ok {
+						cfg.logger().Printf("Slow query %d: %.3f ms against %s with eqp_model=%q job_id=%q strtgy_name=%q (%d slow queries not logged)",
+							queryID, float64(latency)/float64(time.Millisecond), t.Name, key.EqpModel, key.JobID, key.StrategyName, suppressed)
+					}
+				}
+				if cfg.ColdStart {
+					stats.recordColdStart(queryID == 0, firstQuery, latency)
+				}
+				firstQuery = false
+				latencies.Add(latency)
+				cancelBudget()
+				inFlight.Add(-1)
+				held = false
+
+				if cfg.ThinkTime > 0 || cfg.ThinkJitter > 0 {
+					time.Sleep(thinkDelay(rng, cfg.ThinkTime, cfg.ThinkJitter))
+				}
+			}
+			for j := range jobs {
+				if b.ctx.Err() != nil {
+					// Stopped early; drain the remaining jobs unexecuted.
+					continue
+				}
+				runQuery(j)
+			}
+		}(w)
+	}
+
+	// Submit all the jobs to the channel, paced by -rate when set. Jobs are
+	// scheduled against the start time rather than the previous send, so a
+	// late send doesn't lower the overall rate.
+submit:
+	for i := 0; i < cfg.NumQueries; i++ {
+		if opts.Schedule != nil || cfg.Rate > 0 {
+			due := dueTime(i)
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-b.ctx.Done():
+					break submit
+				}
+			}
+		}
+		if b.ctx.Err() != nil {
+			break
+		}
+		var key QueryKey
+		if opts.Keys != nil {
+			var ok bool
+			select {
+			case key, ok = <-opts.Keys:
+			case <-b.ctx.Done():
+				break submit
+			}
+			if !ok {
+				break
+			}
+		} else {
+			key = b.keys[i%len(b.keys)]
+		}
+		// Workers that died in a panic no longer drain the channel.
+		select {
+		case jobs <- job{i, key}:
+		case <-b.ctx.Done():
+			break submit
+		}
+		submitted++
+	}
+	return finish()
+}
@@ -0,0 +1,199 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// nopQuerier answers every query with one row through a single reused
+// statement and iterator, so it allocates nothing and what is counted is the
+// worker's own cost. It serves one worker at a time.
+type nopQuerier struct {
+	stmt nopStatement
+}
+
+type nopStatement struct {
+	iter nopIter
+}
+
+func (q *nopQuerier) Query(string, ...interface{}) Statement { return &q.stmt }
+
+func (s *nopStatement) Consistency(gocql.Consistency) Statement { return s }
+func (s *nopStatement) Observer(gocql.QueryObserver) Statement  { return s }
+func (s *nopStatement) Trace(gocql.Tracer) Statement            { return s }
+func (s *nopStatement) WithContext(context.Context) Statement   { return s }
+func (s *nopStatement) Idempotent(bool) Statement               { return s }
+
+func (s *nopStatement) Iter() RowIter {
+	s.iter.rows = 1
+	return &s.iter
+}
+
+// nopValue is the eqp_model every nopIter row holds.
+var nopValue = []byte("fake")
+
+type nopIter struct {
+	rows int
+}
+
+func (it *nopIter) Columns() []gocql.ColumnInfo         { return fakeColumns }
+func (it *nopIter) NumRows() int                        { return it.rows }
+func (it *nopIter) Close() error                        { return nil }
+func (it *nopIter) MapScan(map[string]interface{}) bool { return false }
+
+func (it *nopIter) Scan(dest ...interface{}) bool {
+	if it.rows == 0 {
+		return false
+	}
+	it.rows--
+	if u, ok := dest[0].(gocql.Unmarshaler); ok {
+		u.UnmarshalCQL(fakeColumns[0].TypeInfo, nopValue)
+	}
+	return true
+}
+
+// pointReadBench returns a one-worker benchmark of n lean point reads
+// against a nopQuerier. With boxed set the keys are not boxed at load, as
+// loadKeys does, so every query boxes the key's strings to bind them.
+func pointReadBench(n int, boxed bool) *benchmark {
+	cfg := Config{Concurrency: 1, NumQueries: n, Seed: 1}
+	keys := make([]QueryKey, 1000)
+	for i := range keys {
+		keys[i] = QueryKey{EqpModel: fmt.Sprintf("model-%d", i%50), JobID: fmt.Sprintf("job-%d", i), StrategyName: fmt.Sprintf("strategy-%d", i%7)}
+	}
+	if !boxed {
+		boxKeyValues(keys)
+	}
+	ctx, abort := context.WithCancelCause(context.Background())
+	return &benchmark{ctx: ctx, cfg: cfg, querier: &nopQuerier{}, keys: keys, abort: abort}
+}
+
+// benchmarkPointRead runs b.N lean point reads through the worker, so
+// allocs/op is what binding, executing and scanning one query costs on the
+// client beyond the querier.
+func benchmarkPointRead(b *testing.B, boxed bool) {
+	bench := pointReadBench(b.N, boxed)
+	defer bench.abort(nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	r := bench.run(runOptions{Discard: true})
+	b.StopTimer()
+	if r.CompletedQueries != int64(b.N) || r.FailedQueries != 0 {
+		b.Fatalf("completed %d queries with %d failures, want %d without", r.CompletedQueries, r.FailedQueries, b.N)
+	}
+}
+
+func BenchmarkPointReadLean(b *testing.B) { benchmarkPointRead(b, false) }
+
+func BenchmarkPointReadBoxed(b *testing.B) { benchmarkPointRead(b, true) }
+
+// TestLeanPointReadDoesNotAllocate checks that a lean point read with boxed
+// keys allocates nothing per query. A pass has a fixed setup cost, so the
+// per-query figure is the difference between a short and a long pass.
+func TestLeanPointReadDoesNotAllocate(t *testing.T) {
+	if !pointReadBench(1, false).cfg.LeanPointRead() {
+		t.Fatal("the default point read is not lean")
+	}
+	pass := func(n int) float64 {
+		return testing.AllocsPerRun(5, func() {
+			bench := pointReadBench(n, false)
+			defer bench.abort(nil)
+			bench.run(runOptions{Discard: true})
+		})
+	}
+	const short, long = 1000, 21000
+	perQuery := (pass(long) - pass(short)) / (long - short)
+	if perQuery > 0.01 {
+		t.Errorf("lean point read allocates %.3f times per query, want 0", perQuery)
+	}
+}
+
+// panicQuerier panics on every tenth query it builds.
+type panicQuerier struct {
+	fakeQuerier
+	n atomic.Int64
+}
+
+func (p *panicQuerier) Query(stmt string, values ...interface{}) Statement {
+	if p.n.Add(1)%10 == 0 {
+		panic("bad key")
+	}
+	return p.fakeQuerier.Query(stmt, values...)
+}
+
+func TestWorkerRecoversQueryPanic(t *testing.T) {
+	cfg := Config{Concurrency: 4, NumQueries: 1000, Seed: 1, RetryBudget: time.Second}
+	keys := []QueryKey{{EqpModel: "model-0", JobID: "job-0", StrategyName: "strategy-0"}}
+	ctx, abort := context.WithCancelCause(context.Background())
+	defer abort(nil)
+	bench := &benchmark{
+		ctx:     ctx,
+		cfg:     cfg,
+		querier: &panicQuerier{fakeQuerier: fakeQuerier{rng: rand.New(rand.NewSource(cfg.Seed))}},
+		keys:    keys,
+		abort:   abort,
+	}
+	r := bench.run(runOptions{Discard: true})
+	if r.CompletedQueries != 1000 || r.Panics != 100 || r.FailedQueries != 100 {
+		t.Errorf("completed %d queries, %d panicked and %d failed, want 1000, 100 and 100", r.CompletedQueries, r.Panics, r.FailedQueries)
+	}
+}
+
+func TestRunStopsOnPanicOutsideQuery(t *testing.T) {
+	cfg := Config{Concurrency: 2, NumQueries: 10, Seed: 1, ReplaySpeed: 1}
+	keys := []QueryKey{{EqpModel: "model-0", JobID: "job-0", StrategyName: "strategy-0"}}
+	ctx, abort := context.WithCancelCause(context.Background())
+	defer abort(nil)
+	bench := &benchmark{
+		ctx:     ctx,
+		cfg:     cfg,
+		querier: &fakeQuerier{rng: rand.New(rand.NewSource(cfg.Seed))},
+		keys:    keys,
+		abort:   abort,
+	}
+	// A schedule shorter than the run panics in the submit loop once the
+	// first query is sent.
+	r := bench.run(runOptions{Schedule: []time.Duration{0}})
+	if !errors.Is(context.Cause(ctx), ErrPanic) {
+		t.Fatalf("cause = %v, want ErrPanic", context.Cause(ctx))
+	}
+	// The first query may be drained unexecuted once the run is stopped.
+	if !r.StoppedEarly || r.CompletedQueries > 1 {
+		t.Errorf("stopped early %v after %d queries, want a partial result of at most 1 query", r.StoppedEarly, r.CompletedQueries)
+	}
+}
+
+func TestMeasureBytesWritten(t *testing.T) {
+	cfg := Config{Concurrency: 2, NumQueries: 100, Seed: 1, MeasureBytes: true}
+	key := QueryKey{EqpModel: "model-0", JobID: "job-0", StrategyName: "strategy-0"}
+	perWrite := int64(len(key.EqpModel) + len(key.JobID) + len(key.StrategyName))
+	ctx, abort := context.WithCancelCause(context.Background())
+	defer abort(nil)
+	bench := &benchmark{
+		ctx:     ctx,
+		cfg:     cfg,
+		querier: &fakeQuerier{rng: rand.New(rand.NewSource(cfg.Seed))},
+		keys:    []QueryKey{key},
+		abort:   abort,
+	}
+	// As a -phases write phase runs.
+	var results []Result
+	for range 2 {
+		r := bench.run(runOptions{Insert: true})
+		if r.BytesWritten != 100*perWrite || r.BytesWrittenPerSecond <= 0 {
+			t.Fatalf("bytes written = %d at %.0f/s, want %d at a positive rate", r.BytesWritten, r.BytesWrittenPerSecond, 100*perWrite)
+		}
+		results = append(results, r)
+	}
+	m := mergeSequential(cfg, results)
+	if m.BytesWritten != 200*perWrite || m.BytesWrittenPerSecond <= 0 {
+		t.Errorf("merged bytes written = %d at %.0f/s, want %d at a positive rate", m.BytesWritten, m.BytesWrittenPerSecond, 200*perWrite)
+	}
+}
@@ -0,0 +1,75 @@
+package bench
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// bucketLayout is the format of day buckets, which gocql binds to a CQL date
+// column as-is.
+const bucketLayout = "2006-01-02"
+
+// ParseDateRange parses a -date-range spec "2025-01-01:2025-01-31" into the
+// list of day buckets it covers, oldest first.
+func ParseDateRange(spec string) ([]string, error) {
+	fromStr, toStr, ok := strings.Cut(spec, ":")
+	if !ok {
+		return nil, fmt.Errorf("expected FROM:TO, got %q", spec)
+	}
+	from, err := time.Parse(bucketLayout, strings.TrimSpace(fromStr))
+	if err != nil {
+		return nil, err
+	}
+	to, err := time.Parse(bucketLayout, strings.TrimSpace(toStr))
+	if err != nil {
+		return nil, err
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("range ends before it starts: %q", spec)
+	}
+	var days []string
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		days = append(days, d.Format(bucketLayout))
+	}
+	return days, nil
+}
+
+// bucketPicker spreads queries across day buckets. With a decay below 1 each
+// bucket receives decay times the queries of the next more recent one, which
+// models time-series reads where recent buckets are hot.
+type bucketPicker struct {
+	days   []string
+	picker weightedPicker
+}
+
+func newBucketPicker(days []string, decay float64) *bucketPicker {
+	// Weights are assigned newest first.
+	weights := make([]float64, len(days))
+	weight := 1.0
+	for i := len(days) - 1; i >= 0; i-- {
+		weights[i] = weight
+		weight *= decay
+	}
+	return &bucketPicker{days: days, picker: newWeightedPicker(weights)}
+}
+
+// pick returns a bucket using the worker's rand source.
+func (p *bucketPicker) pick(rng *rand.Rand) string {
+	return p.days[p.picker.pick(rng)]
+}
+
+// checkKeyBuckets makes sure every key can bind the -bucket-column: without
+// a -date-range to pick from, each key must carry its own bucket.
+func checkKeyBuckets(keys []QueryKey, cfg Config) error {
+	if cfg.BucketColumn == "" || len(cfg.Buckets) > 0 {
+		return nil
+	}
+	for i, k := range keys {
+		if k.Bucket == "" {
+			return fmt.Errorf("key %d (eqp_model=%q job_id=%q) has no bucket for -bucket-column %s; add one or give -date-range", i, k.EqpModel, k.JobID, cfg.BucketColumn)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package bench
+
+import (
+	"reflect"
+	"time"
+)
+
+// timeType is special-cased by valueBytes: time.Time travels as an 8-byte
+// timestamp but its Go struct is larger.
+var timeType = reflect.TypeOf(time.Time{})
+
+// scannedBytes estimates the payload size of one scanned row for
+// -measure-bytes. It counts value bytes only (string and blob lengths,
+// fixed-width numbers, collection elements), not protocol framing, so it
+// is a lower bound on what crossed the network.
+func scannedBytes(dest []interface{}) int64 {
+	var n int64
+	for _, d := range dest {
+		n += valueBytes(reflect.ValueOf(d))
+	}
+	return n
+}
+
+// boundBytes estimates the payload a write sends for -measure-bytes from
+// its bind values, counted the way scannedBytes counts a row.
+func boundBytes(values []interface{}) int64 {
+	return scannedBytes(values)
+}
+
+func valueBytes(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return 0
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return valueBytes(v.Elem())
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return int64(v.Len())
+		}
+		var n int64
+		for i := 0; i < v.Len(); i++ {
+			n += valueBytes(v.Index(i))
+		}
+		return n
+	case reflect.Map:
+		var n int64
+		iter := v.MapRange()
+		for iter.Next() {
+			n += valueBytes(iter.Key()) + valueBytes(iter.Value())
+		}
+		return n
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return 8
+		}
+		var n int64
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				n += valueBytes(v.Field(i))
+			}
+		}
+		return n
+	default:
+		return int64(v.Type().Size())
+	}
+}
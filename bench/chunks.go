@@ -0,0 +1,194 @@
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// keyChunker streams a keys file (JSON array or NDJSON) in chunks, so a
+// -chunk-size run only ever holds one chunk of keys in memory. With
+// bestEffort set, entries that fail to decode are skipped and counted in
+// skipped and logged to logger, as parseKeys does for a loaded file.
+type keyChunker struct {
+	f *os.File
+	// dec reads the elements of a JSON array; lines reads NDJSON, and
+	// lineNo is the file line of the last one read.
+	dec        *json.Decoder
+	lines      *bufio.Scanner
+	lineNo     int
+	bestEffort bool
+	logger     *log.Logger
+	read       int
+	skipped    int
+	done       bool
+}
+
+func openKeyChunker(path string, bestEffort bool, logger *log.Logger) (*keyChunker, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(f)
+	c := &keyChunker{f: f, bestEffort: bestEffort, logger: logger}
+	array := false
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if b == '\n' {
+			c.lineNo++
+		}
+		if b == ' ' || b == '\t' || b == '\r' || b == '\n' {
+			continue
+		}
+		array = b == '['
+		r.UnreadByte()
+		break
+	}
+	if !array {
+		c.lines = bufio.NewScanner(r)
+		c.lines.Buffer(make([]byte, 64*1024), 1024*1024)
+		return c, nil
+	}
+	c.dec = json.NewDecoder(r)
+	// Step into the array so Decode reads its elements.
+	if _, err := c.dec.Token(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// record returns the next undecoded entry, or nil at the end of the file.
+func (c *keyChunker) record() ([]byte, error) {
+	if c.lines != nil {
+		for c.lines.Scan() {
+			c.lineNo++
+			if line := bytes.TrimSpace(c.lines.Bytes()); len(line) > 0 {
+				return line, nil
+			}
+		}
+		return nil, c.lines.Err()
+	}
+	if !c.dec.More() {
+		return nil, nil
+	}
+	var raw json.RawMessage
+	if err := c.dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// next returns up to n more keys; an empty slice means the file is done.
+func (c *keyChunker) next(n int) ([]QueryKey, error) {
+	var keys []QueryKey
+	for len(keys) < n && !c.done {
+		raw, err := c.record()
+		if err == nil && raw == nil {
+			c.done = true
+			break
+		}
+		var key QueryKey
+		if err != nil {
+			// The reader cannot find the next entry past a syntax error
+			// or an overlong line, so the file ends here.
+			c.done = true
+		} else {
+			err = json.Unmarshal(raw, &key)
+		}
+		if err != nil {
+			// Numbered as parseKeys numbers them: the 1-based element of
+			// an array, the file line of NDJSON.
+			entry := fmt.Sprintf("key %d", c.read+c.skipped+1)
+			skipping := entry
+			if c.lines != nil {
+				entry = fmt.Sprintf("line %d", c.lineNo)
+				skipping = "key on " + entry
+			}
+			if !c.bestEffort {
+				return nil, fmt.Errorf("%s: %w", entry, err)
+			}
+			c.logger.Printf("Skipping unparseable %s: %v", skipping, err)
+			c.skipped++
+			continue
+		}
+		c.read++
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (c *keyChunker) Close() error {
+	return c.f.Close()
+}
+
+// runChunked runs the workload over the keys file one chunk of
+// cfg.ChunkSize keys at a time, each key queried once, until the file or
+// cfg.NumQueries runs out. Peak memory is bounded by the chunk size, at the
+// price that keys are only ever mixed within a chunk, never across the whole
+// set. The chunk results are combined as consecutive phases of one run.
+func (b *benchmark) runChunked() (Result, error) {
+	cfg := b.cfg
+	chunker, err := openKeyChunker(cfg.KeysFilePath, cfg.BestEffort, cfg.logger())
+	if err != nil {
+		return Result{}, fmt.Errorf("reading keys file: %w", err)
+	}
+	defer chunker.Close()
+
+	var results []Result
+	remaining := cfg.NumQueries
+	for chunk := 1; remaining > 0; chunk++ {
+		keys, err := chunker.next(min(cfg.ChunkSize, remaining))
+		if err != nil {
+			return Result{}, err
+		}
+		if len(keys) == 0 {
+			break
+		}
+		if err := checkKeyBuckets(keys, cfg); err != nil {
+			return Result{}, err
+		}
+		if err := applyKeyTypes(keys, cfg.KeyTypes); err != nil {
+			return Result{}, fmt.Errorf("converting keys with -key-types: %w", err)
+		}
+		if cfg.LeanPointRead() {
+			boxKeyValues(keys)
+		}
+		b.keys = keys
+		if chunk == 1 && cfg.Warmup > 0 {
+			cfg.printf("Warming up with %d queries (results discarded)...\n", cfg.Warmup)
+			b.run(runOptions{Queries: cfg.Warmup, Discard: true})
+		}
+		cfg.printf("Chunk %d: keys %d-%d...\n", chunk, chunker.read-len(keys)+1, chunker.read)
+		r := b.run(runOptions{Queries: len(keys)})
+		cfg.printf("Chunk %d: %d queries in %.2f seconds, %.2f queries/second, p99 %.3f ms, hit rate %.2f%%\n",
+			chunk, r.CompletedQueries, r.TotalSeconds, r.QPS, r.P99Ms, r.HitRatePct)
+		results = append(results, r)
+		remaining -= len(keys)
+		if r.StoppedEarly {
+			break
+		}
+	}
+	reportKeyCoercions(cfg.logger())
+	if chunker.skipped > 0 {
+		cfg.logger().Printf("Skipped %d unparseable entries in the keys file.", chunker.skipped)
+	}
+	if len(results) == 0 {
+		return Result{}, fmt.Errorf("no keys found in %s; run the data inserter first", cfg.KeysFilePath)
+	}
+
+	merged := mergeSequential(cfg, results)
+	merged.Chunks = len(results)
+	return merged, nil
+}
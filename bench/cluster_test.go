@@ -0,0 +1,80 @@
+package bench
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeClusterConfig writes body to a -cluster-config file and returns its
+// path.
+func writeClusterConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cluster.json")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadClusterSettingsDefaults(t *testing.T) {
+	s, err := LoadClusterSettings(writeClusterConfig(t, `{"keyspace": "bench"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultClusterSettings()
+	want.Keyspace = "bench"
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("LoadClusterSettings = %+v, want the defaults with keyspace bench: %+v", s, want)
+	}
+}
+
+func TestLoadClusterSettingsUnknownField(t *testing.T) {
+	_, err := LoadClusterSettings(writeClusterConfig(t, `{"hosts": ["10.0.0.1"], "keyspcae": "bench"}`))
+	if err == nil || !strings.Contains(err.Error(), "keyspcae") {
+		t.Errorf("LoadClusterSettings with a misspelt field: err = %v, want an unknown field error naming it", err)
+	}
+}
+
+func TestLoadClusterSettingsTLS(t *testing.T) {
+	s, err := LoadClusterSettings(writeClusterConfig(t, `{
+		"hosts": ["cass1", "cass2"],
+		"tls": {
+			"ca_file": "/etc/cassandra/ca.pem",
+			"cert_file": "/etc/cassandra/client.pem",
+			"key_file": "/etc/cassandra/client.key",
+			"server_name": "cassandra.example.com",
+			"insecure_skip_verify": true
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := TLSSettings{
+		CAFile:             "/etc/cassandra/ca.pem",
+		CertFile:           "/etc/cassandra/client.pem",
+		KeyFile:            "/etc/cassandra/client.key",
+		ServerName:         "cassandra.example.com",
+		InsecureSkipVerify: true,
+	}
+	if s.TLS == nil || *s.TLS != want {
+		t.Fatalf("TLS = %+v, want %+v", s.TLS, want)
+	}
+
+	cluster, err := s.NewCluster(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := cluster.SslOpts
+	if opts == nil {
+		t.Fatal("NewCluster left SslOpts unset with TLS configured")
+	}
+	if opts.CaPath != want.CAFile || opts.CertPath != want.CertFile || opts.KeyPath != want.KeyFile {
+		t.Errorf("SslOpts paths = %q, %q, %q, want %q, %q, %q", opts.CaPath, opts.CertPath, opts.KeyPath, want.CAFile, want.CertFile, want.KeyFile)
+	}
+	if opts.Config.ServerName != want.ServerName || !opts.Config.InsecureSkipVerify {
+		t.Errorf("SslOpts TLS config = server name %q, skip verify %v, want %q, true", opts.Config.ServerName, opts.Config.InsecureSkipVerify, want.ServerName)
+	}
+}
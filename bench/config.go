@@ -0,0 +1,185 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// Config holds the settings for a benchmark run. The CLI fills it in from
+// its flags, which the fields and messages refer to by name.
+type Config struct {
+	Concurrency  int
+	NumQueries   int
+	KeysFilePath string
+
+	BestEffort  bool
+	ThinkTime   time.Duration
+	ThinkJitter time.Duration
+	OpTimeout   time.Duration
+	Retries     int
+	RetryBudget time.Duration
+	TraceSample float64
+	CountOnly   bool
+	NoPrepare   bool
+
+	ResultBuffer int
+	Targets      []Target
+
+	Cluster ClusterSettings
+
+	Rate          float64
+	SLAP99Ms      float64
+	MaxRate       float64
+	SLAProbes     int
+	ProbeDuration time.Duration
+
+	Buckets      []string
+	BucketColumn string
+	BucketDecay  float64
+
+	Replay      bool
+	ReplaySpeed float64
+
+	ConsistencyMix []ConsistencyShare
+
+	PhaseTiming bool
+
+	Warmup    int
+	Prime     bool
+	ColdStart bool
+
+	PartitionOnly bool
+
+	KeyTypes []string
+
+	Repeat     int
+	FixedOrder bool
+	Seed       int64
+
+	SampleErrors int
+
+	FailFast bool
+
+	ValidateKeys bool
+
+	MeasureBytes bool
+
+	SlowQueryMs float64
+
+	FastPath       bool
+	FallbackOnMiss bool
+
+	ReportDC bool
+
+	ChunkSize int
+
+	Limit   int
+	OrderBy string
+
+	PoolWait bool
+
+	SplitPrepare float64
+
+	RunRetries    int
+	RunRetryProbe int
+	RunRetryDelay time.Duration
+
+	StreamKeys bool
+
+	ProgressInterval time.Duration
+	WebhookURL       string
+
+	MinKeys int
+
+	MetadataColumn string
+	ExpiryWindow   time.Duration
+
+	NullKeyMiss bool
+
+	RateSchedule []RateStep
+
+	InList int
+
+	Tag string
+
+	WriteMix float64
+
+	CounterTable string
+
+	RetryNonIdempotent bool
+
+	ReportHosts bool
+
+	Phases []Phase
+
+	KeysFormat  string
+	CSVColumns  []CSVColumn
+	CSVNoHeader bool
+
+	MaxPartitions int
+
+	PrintSampleQuery bool
+
+	MapScan bool
+
+	SweepConsistency []gocql.Consistency
+
+	// Timeline records the per-second completions, errors and in-flight
+	// queries of the measured passes, for Result.WriteTimeline.
+	Timeline bool
+
+	// TraceOut receives the CQL trace events of the queries -cql-trace-sample
+	// picks.
+	TraceOut io.Writer
+
+	// Out receives the progress lines of the run, and Log its warnings and
+	// notices such as skipped keys file entries. Either may be nil to
+	// discard them.
+	Out io.Writer
+	Log *log.Logger
+
+	// NewDisplay, when set, starts a Display for each measured pass, which
+	// then shows its progress snapshots in place of the progress lines.
+	// When it fails the lines are written instead.
+	NewDisplay func() (Display, error)
+}
+
+// discardLog stands in for a nil Config.Log.
+var discardLog = log.New(io.Discard, "", 0)
+
+// orDiscard returns l, or discardLog when l is nil.
+func orDiscard(l *log.Logger) *log.Logger {
+	if l == nil {
+		return discardLog
+	}
+	return l
+}
+
+// logger returns where the run logs to.
+func (cfg Config) logger() *log.Logger {
+	return orDiscard(cfg.Log)
+}
+
+// printf writes a progress line to cfg.Out, if set.
+func (cfg Config) printf(format string, args ...any) {
+	if cfg.Out != nil {
+		fmt.Fprintf(cfg.Out, format, args...)
+	}
+}
+
+// SplitList splits a comma-separated flag value, dropping empty entries,
+// as the Parse functions do.
+func SplitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
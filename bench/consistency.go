@@ -0,0 +1,126 @@
+package bench
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/gocql/gocql"
+)
+
+// ConsistencyShare is one entry of a -consistency-mix spec.
+type ConsistencyShare struct {
+	Level  gocql.Consistency
+	Weight float64
+}
+
+// ParseConsistencyMix parses a spec such as "LOCAL_ONE:0.8,QUORUM:0.2".
+// The weights must sum to 1 (within 1%).
+func ParseConsistencyMix(spec string) ([]ConsistencyShare, error) {
+	var mix []ConsistencyShare
+	var sum float64
+	for _, part := range SplitList(spec) {
+		name, weightStr, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("expected LEVEL:WEIGHT, got %q", part)
+		}
+		level, err := gocql.ParseConsistencyWrapper(strings.ToUpper(strings.TrimSpace(name)))
+		if err != nil {
+			return nil, err
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(weightStr), 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight %q for %s", weightStr, name)
+		}
+		mix = append(mix, ConsistencyShare{Level: level, Weight: weight})
+		sum += weight
+	}
+	if len(mix) == 0 {
+		return nil, fmt.Errorf("no consistency levels in %q", spec)
+	}
+	if math.Abs(sum-1) > 0.01 {
+		return nil, fmt.Errorf("weights sum to %.3f, expected 1.0", sum)
+	}
+	return mix, nil
+}
+
+// newConsistencyPicker returns a picker over the mix's weights.
+func newConsistencyPicker(mix []ConsistencyShare) weightedPicker {
+	weights := make([]float64, len(mix))
+	for i, c := range mix {
+		weights[i] = c.Weight
+	}
+	return newWeightedPicker(weights)
+}
+
+// ParseConsistencySweep parses a -sweep-consistency spec such as
+// "one,local_quorum,quorum,all".
+func ParseConsistencySweep(spec string) ([]gocql.Consistency, error) {
+	var levels []gocql.Consistency
+	for _, name := range SplitList(spec) {
+		level, err := gocql.ParseConsistencyWrapper(strings.ToUpper(strings.TrimSpace(name)))
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(levels, level) {
+			return nil, fmt.Errorf("%s is listed twice", level)
+		}
+		levels = append(levels, level)
+	}
+	if len(levels) == 0 {
+		return nil, fmt.Errorf("no consistency levels in %q", spec)
+	}
+	return levels, nil
+}
+
+// sweepConsistency runs the measured pass once per -sweep-consistency
+// level, every query at that level, to show what stronger consistency
+// costs. With -repeat each level is repeated and reported by its medians.
+// The levels' latencies (the last iteration's, with -repeat) are compared
+// pairwise like -repeat iterations. The
+// caller gets the last level's result with every level attached.
+func (b *benchmark) sweepConsistency(opts runOptions) Result {
+	levels := b.cfg.SweepConsistency
+
+	var result Result
+	var summaries []ConsistencyLevelResult
+	var hists []*hdrhistogram.Histogram
+	for i, level := range levels {
+		b.cfg.printf("=== Consistency %d/%d: %s ===\n", i+1, len(levels), level)
+		opts.ConsistencyMix = []ConsistencyShare{{Level: level, Weight: 1}}
+		if b.cfg.Repeat > 1 {
+			result = b.repeatRuns(opts)
+		} else {
+			result = b.run(opts)
+		}
+		if result.StoppedEarly {
+			break
+		}
+		s := ConsistencyLevelResult{
+			Consistency:   level.String(),
+			QPS:           result.QPS,
+			AvgLatencyMs:  result.AvgLatencyMs,
+			P50Ms:         result.P50Ms,
+			P99Ms:         result.P99Ms,
+			MaxMs:         result.MaxMs,
+			HitRatePct:    result.HitRatePct,
+			FailedQueries: result.FailedQueries,
+		}
+		if len(result.Iterations) > 0 {
+			s.QPS, s.AvgLatencyMs, s.P99Ms = result.MedianQPS, result.MedianAvgLatencyMs, result.MedianP99Ms
+		}
+		b.cfg.printf("=== Consistency %s: %.2f queries/second, p50 %.3f ms, p99 %.3f ms, hit rate %.2f%% ===\n",
+			s.Consistency, s.QPS, s.P50Ms, s.P99Ms, s.HitRatePct)
+		summaries = append(summaries, s)
+		hists = append(hists, result.latencyHist)
+	}
+
+	result.ConsistencySweep = summaries
+	if len(hists) > 1 {
+		result.ConsistencyDifference = compareIterations(hists)
+	}
+	return result
+}
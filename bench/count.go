@@ -0,0 +1,46 @@
+package bench
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseCount parses a non-negative count with an optional k (thousand) or m
+// (million) suffix, so "10k" is 10000 and "1.5m" is 1500000. The result must
+// be a whole number that fits in an int.
+func ParseCount(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	multiplier := 1.0
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'k', 'K':
+			multiplier, s = 1e3, s[:len(s)-1]
+		case 'm', 'M':
+			multiplier, s = 1e6, s[:len(s)-1]
+		}
+	}
+	if s == "" {
+		return 0, fmt.Errorf("invalid count: missing number")
+	}
+	if multiplier == 1 {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid count %q", s)
+		}
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, fmt.Errorf("invalid count %q", s)
+	}
+	v := f * multiplier
+	if v != math.Trunc(v) {
+		return 0, fmt.Errorf("count %q is not a whole number", s)
+	}
+	if v > math.MaxInt32 {
+		return 0, fmt.Errorf("count %q is too large", s)
+	}
+	return int(v), nil
+}
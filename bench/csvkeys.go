@@ -0,0 +1,133 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+)
+
+// csvFields are the QueryKey fields a CSV column can map to.
+var csvFields = append(slices.Clone(keyFields), "bucket")
+
+// CSVColumn maps a -keys-format csv column to a QueryKey field. Header is
+// the column's header name; it is empty with -no-header, where columns are
+// matched by position and Field "-" skips one.
+type CSVColumn struct {
+	Field  string
+	Header string
+}
+
+// ParseCSVColumns parses a -csv-columns spec. With a header row each entry
+// is FIELD or FIELD=HEADER, naming the header column a field is read from;
+// without one the entries name the field of each column in order.
+func ParseCSVColumns(spec string, noHeader bool) ([]CSVColumn, error) {
+	if spec == "" {
+		spec = strings.Join(keyFields, ",")
+	}
+	var cols []CSVColumn
+	seen := make(map[string]bool)
+	for _, part := range SplitList(spec) {
+		field, header, renamed := strings.Cut(part, "=")
+		field = strings.TrimSpace(field)
+		header = strings.TrimSpace(header)
+		if noHeader && renamed {
+			return nil, fmt.Errorf("%q renames a header column, but -no-header has none; list the fields in column order", part)
+		}
+		if field == "-" && noHeader {
+			cols = append(cols, CSVColumn{Field: field})
+			continue
+		}
+		if !slices.Contains(csvFields, field) {
+			return nil, fmt.Errorf("unknown field %q (fields are %s)", field, strings.Join(csvFields, ", "))
+		}
+		if seen[field] {
+			return nil, fmt.Errorf("field %s is mapped twice", field)
+		}
+		seen[field] = true
+		if !noHeader && header == "" {
+			header = field
+		}
+		cols = append(cols, CSVColumn{Field: field, Header: header})
+	}
+	for _, f := range keyFields {
+		if !seen[f] {
+			return nil, fmt.Errorf("no column for %s", f)
+		}
+	}
+	return cols, nil
+}
+
+// parseKeysCSV decodes a -keys-format csv keys file. Quoted fields follow
+// RFC 4180. Unless noHeader is set the first record is a header row, and
+// cols picks the columns by name; otherwise cols gives the field of each
+// column by position. Columns not mapped are ignored.
+//
+// With bestEffort set, records that fail to parse or are too short are
+// skipped instead of aborting the load, logged to logger and counted in the
+// skipped count.
+func parseKeysCSV(data []byte, cols []CSVColumn, noHeader, bestEffort bool, logger *log.Logger) ([]QueryKey, int, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.ReuseRecord = true
+
+	// index[i] is the record position of cols[i].
+	index := make([]int, len(cols))
+	for i := range cols {
+		index[i] = i
+	}
+	if !noHeader {
+		header, err := r.Read()
+		if err != nil {
+			return nil, 0, fmt.Errorf("reading header row: %w", err)
+		}
+		for i, c := range cols {
+			index[i] = slices.IndexFunc(header, func(h string) bool { return strings.TrimSpace(h) == c.Header })
+			if index[i] < 0 {
+				return nil, 0, fmt.Errorf("header row has no %q column for %s (columns are %s)", c.Header, c.Field, strings.Join(header, ", "))
+			}
+		}
+	}
+	width := slices.Max(index) + 1
+
+	var keys []QueryKey
+	skipped := 0
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err == nil && len(record) < width {
+			line, _ := r.FieldPos(0)
+			err = fmt.Errorf("record on line %d has %d fields, want at least %d", line, len(record), width)
+		}
+		if err != nil {
+			if !bestEffort {
+				return nil, 0, err
+			}
+			logger.Printf("Skipping unparseable CSV record: %v", err)
+			skipped++
+			continue
+		}
+		var key QueryKey
+		for i, c := range cols {
+			v := record[index[i]]
+			switch c.Field {
+			case "eqp_model":
+				key.EqpModel = v
+			case "job_id":
+				key.JobID = v
+			case "strtgy_name":
+				key.StrategyName = v
+			case "bucket":
+				key.Bucket = v
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, skipped, nil
+}
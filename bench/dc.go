@@ -0,0 +1,20 @@
+package bench
+
+import (
+	"context"
+
+	"github.com/gocql/gocql"
+)
+
+// dcObserver records the datacenter of the coordinator each query attempt
+// went to, from the host metadata gocql passes to query observers.
+type dcObserver struct {
+	stats *runStats
+}
+
+func (o dcObserver) ObserveQuery(_ context.Context, q gocql.ObservedQuery) {
+	if q.Host == nil {
+		return
+	}
+	o.stats.recordDC(q.Host.DataCenter())
+}
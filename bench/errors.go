@@ -0,0 +1,90 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/gocql/gocql"
+)
+
+// ErrFailFast is the cancellation cause of a run stopped by -fail-fast.
+var ErrFailFast = errors.New("-fail-fast")
+
+// ErrNotReady is the cancellation cause of a run whose first -run-retry-probe
+// queries all failed, which -run-retries takes to mean the cluster was not
+// up yet.
+var ErrNotReady = errors.New("cluster not ready")
+
+// ErrInterrupted is the cancellation cause of a run stopped by SIGINT or
+// SIGTERM. A caller cancelling ctx with it gets the partial Result back
+// like the CLI does.
+var ErrInterrupted = errors.New("interrupted")
+
+// ErrSchema is the cancellation cause of a run stopped because the table or
+// a column the query uses does not exist on the cluster.
+var ErrSchema = errors.New("schema mismatch")
+
+// ErrPanic is the cancellation cause of a run stopped by a panic outside a
+// query, a bug in the benchmark itself rather than in one query.
+var ErrPanic = errors.New("panic")
+
+// stopCauses are the causes of a run that stopped early but still has a
+// partial result worth reporting.
+var stopCauses = []error{ErrFailFast, ErrNotReady, ErrInterrupted, ErrSchema, ErrPanic}
+
+// IsStopCause reports whether err wraps one of stopCauses, so the Result
+// returned with it is partial but still worth reporting.
+func IsStopCause(err error) bool {
+	for _, cause := range stopCauses {
+		if errors.Is(err, cause) {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaErrorMarkers are the fragments of the server messages Cassandra
+// returns for a missing keyspace, table or column.
+var schemaErrorMarkers = []string{
+	"unconfigured table",
+	"undefined column name",
+	"undefined name",
+	"unknown identifier",
+	"does not exist",
+}
+
+// schemaProblem returns the server's message when err is an invalid-request
+// error about a missing keyspace, table or column, and "" otherwise. Such an
+// error fails every query the same way, so it is worth one clear message
+// rather than one per query.
+func schemaProblem(err error) string {
+	var reqErr gocql.RequestError
+	if !errors.As(err, &reqErr) || reqErr.Code() != gocql.ErrCodeInvalid {
+		return ""
+	}
+	msg := strings.ToLower(reqErr.Message())
+	for _, marker := range schemaErrorMarkers {
+		if strings.Contains(msg, marker) {
+			return reqErr.Message()
+		}
+	}
+	return ""
+}
+
+// errQueryPanic is the error a query that panicked is recorded with.
+var errQueryPanic = errors.New("query panicked")
+
+// isDeadlineExceeded reports whether err came from the per-operation
+// client-side deadline rather than from the cluster.
+func isDeadlineExceeded(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// isServerTimeout reports whether err is a coordinator-side read or write
+// timeout returned by Cassandra.
+func isServerTimeout(err error) bool {
+	var readTimeout *gocql.RequestErrReadTimeout
+	var writeTimeout *gocql.RequestErrWriteTimeout
+	return errors.As(err, &readTimeout) || errors.As(err, &writeTimeout)
+}
@@ -0,0 +1,96 @@
+package bench
+
+import (
+	"context"
+	"math"
+	"math/rand"
+)
+
+// ExistenceConcurrency is the number of workers an -existence-check uses,
+// low enough to leave a production cluster undisturbed.
+const ExistenceConcurrency = 4
+
+// existenceZ is the normal quantile for the 95% confidence interval of an
+// -existence-check.
+const existenceZ = 1.96
+
+// ExistenceResult is the outcome of CheckExistence. Checked counts the
+// sampled reads that did not fail, FoundPct is the percentage of them that
+// found their key, and Low and High bound that fraction with 95%
+// confidence.
+type ExistenceResult struct {
+	Keys         int     `json:"keys"`
+	Sampled      int     `json:"sampled"`
+	Found        int64   `json:"found"`
+	Checked      int64   `json:"checked"`
+	FoundPct     float64 `json:"found_pct"`
+	Failed       int64   `json:"failed"`
+	Low          float64 `json:"low"`
+	High         float64 `json:"high"`
+	StoppedEarly bool    `json:"stopped_early,omitempty"`
+}
+
+// CheckExistence is the -existence-check pre-scan: it reads a random
+// sample of n keys of the keys file with LIMIT 1 at low concurrency and
+// reports what fraction exists in the table, without running the
+// benchmark.
+func CheckExistence(ctx context.Context, querier Querier, cfg Config, n int) (ExistenceResult, error) {
+	keys, _, err := loadKeys(&cfg)
+	if err != nil {
+		return ExistenceResult{}, err
+	}
+
+	// A partial Fisher-Yates shuffle picks the sample without replacement.
+	n = min(n, len(keys))
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	sample := append([]QueryKey(nil), keys...)
+	for i := 0; i < n; i++ {
+		j := i + rng.Intn(len(sample)-i)
+		sample[i], sample[j] = sample[j], sample[i]
+	}
+	sample = sample[:n]
+
+	cfg.Concurrency = ExistenceConcurrency
+	cfg.NumQueries = n
+	cfg.CountOnly = true
+	cfg.MetadataColumn = ""
+	// LIMIT 1 replaces any clustering range -limit and -order-by asked for.
+	cfg.Limit = 0
+	cfg.OrderBy = ""
+	cfg.ProgressInterval = 0
+	cfg.printf("Checking %d of %d keys for existence with %d workers...\n", n, len(keys), cfg.Concurrency)
+	ctx, abort := context.WithCancelCause(ctx)
+	defer abort(nil)
+	b := &benchmark{ctx: ctx, cfg: cfg, querier: querier, keys: sample, targets: cfg.Targets, abort: abort}
+	r := b.run(runOptions{Discard: true})
+
+	// Failed reads say nothing about whether the key exists.
+	checked := r.CompletedQueries - r.FailedQueries
+	low, high := wilsonInterval(r.SuccessfulQueries, checked, existenceZ)
+	return ExistenceResult{
+		Keys:         len(keys),
+		Sampled:      n,
+		Found:        r.SuccessfulQueries,
+		Checked:      checked,
+		FoundPct:     percent(r.SuccessfulQueries, checked),
+		Failed:       r.FailedQueries,
+		Low:          low,
+		High:         high,
+		StoppedEarly: r.StoppedEarly,
+	}, nil
+}
+
+// wilsonInterval returns the Wilson score interval for hits successes out
+// of n trials at normal quantile z. Unlike the normal approximation it
+// stays within [0, 1] and is sound for hit rates near 0 or 100%.
+func wilsonInterval(hits, n int64, z float64) (low, high float64) {
+	if n == 0 {
+		return 0, 1
+	}
+	p := float64(hits) / float64(n)
+	nf := float64(n)
+	denom := 1 + z*z/nf
+	center := (p + z*z/(2*nf)) / denom
+	spread := z * math.Sqrt(p*(1-p)/nf+z*z/(4*nf*nf)) / denom
+	return max(0, center-spread), min(1, center+spread)
+}
@@ -0,0 +1,124 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// fakeQuerier is an in-memory Querier for -self-test. Every query takes
+// latency ± jitter, fails with errorRate (half of the failures as server
+// read timeouts, half as generic errors) and otherwise finds no row with
+// missRate.
+type fakeQuerier struct {
+	latency   time.Duration
+	jitter    time.Duration
+	errorRate float64
+	missRate  float64
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewFakeQuerier returns the fakeQuerier -self-test runs against, with its
+// random draws seeded by seed.
+func NewFakeQuerier(latency, jitter time.Duration, errorRate, missRate float64, seed int64) Querier {
+	return &fakeQuerier{
+		latency:   latency,
+		jitter:    jitter,
+		errorRate: errorRate,
+		missRate:  missRate,
+		rng:       rand.New(rand.NewSource(seed)),
+	}
+}
+
+var errFakeFailure = errors.New("self-test: injected failure")
+
+// fakeColumns is the single text column every fake row has.
+var fakeColumns = []gocql.ColumnInfo{{Name: "eqp_model", TypeInfo: gocql.NewNativeType(4, gocql.TypeVarchar, "")}}
+
+func (f *fakeQuerier) Query(stmt string, values ...interface{}) Statement {
+	return &fakeStatement{f: f, ctx: context.Background()}
+}
+
+type fakeStatement struct {
+	f        *fakeQuerier
+	ctx      context.Context
+	observer gocql.QueryObserver
+}
+
+func (s *fakeStatement) Consistency(gocql.Consistency) Statement   { return s }
+func (s *fakeStatement) Observer(o gocql.QueryObserver) Statement  { s.observer = o; return s }
+func (s *fakeStatement) Trace(gocql.Tracer) Statement              { return s }
+func (s *fakeStatement) WithContext(ctx context.Context) Statement { s.ctx = ctx; return s }
+func (s *fakeStatement) Idempotent(bool) Statement                 { return s }
+
+func (s *fakeStatement) Iter() RowIter {
+	f := s.f
+	f.mu.Lock()
+	d := f.latency
+	if f.jitter > 0 {
+		d += time.Duration(f.rng.Int63n(int64(2*f.jitter)+1)) - f.jitter
+	}
+	fail := f.rng.Float64() < f.errorRate
+	timeout := f.rng.Intn(2) == 0
+	miss := f.rng.Float64() < f.missRate
+	f.mu.Unlock()
+
+	start := time.Now()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-s.ctx.Done():
+		return &fakeIter{err: s.ctx.Err()}
+	case <-timer.C:
+	}
+	if s.observer != nil {
+		s.observer.ObserveQuery(s.ctx, gocql.ObservedQuery{Start: start, End: time.Now()})
+	}
+	switch {
+	case fail && timeout:
+		return &fakeIter{err: fmt.Errorf("self-test: injected read timeout: %w", &gocql.RequestErrReadTimeout{})}
+	case fail:
+		return &fakeIter{err: errFakeFailure}
+	}
+	return &fakeIter{rows: map[bool]int{true: 0, false: 1}[miss]}
+}
+
+type fakeIter struct {
+	rows int
+	err  error
+}
+
+func (it *fakeIter) Columns() []gocql.ColumnInfo { return fakeColumns }
+func (it *fakeIter) NumRows() int                { return it.rows }
+func (it *fakeIter) Close() error                { return it.err }
+
+func (it *fakeIter) MapScan(m map[string]interface{}) bool {
+	if it.err != nil || it.rows == 0 {
+		return false
+	}
+	it.rows--
+	for _, col := range fakeColumns {
+		m[col.Name] = "fake"
+	}
+	return true
+}
+
+func (it *fakeIter) Scan(dest ...interface{}) bool {
+	if it.err != nil || it.rows == 0 {
+		return false
+	}
+	it.rows--
+	if len(dest) > 0 {
+		if u, ok := dest[0].(gocql.Unmarshaler); ok {
+			u.UnmarshalCQL(fakeColumns[0].TypeInfo, []byte("fake"))
+		}
+	}
+	return true
+}
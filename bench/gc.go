@@ -0,0 +1,21 @@
+package bench
+
+import (
+	"runtime"
+	"time"
+)
+
+// gcSince reports the GC cycles completed since start, their total
+// stop-the-world pause and the longest single pause. Only the last 256
+// pauses are kept by the runtime, so the maximum is over those.
+func gcSince(start *runtime.MemStats) (cycles uint32, total, longest time.Duration) {
+	var now runtime.MemStats
+	runtime.ReadMemStats(&now)
+	cycles = now.NumGC - start.NumGC
+	total = time.Duration(now.PauseTotalNs - start.PauseTotalNs)
+	for i := uint32(0); i < cycles && i < uint32(len(now.PauseNs)); i++ {
+		pause := time.Duration(now.PauseNs[(now.NumGC-1-i)%uint32(len(now.PauseNs))])
+		longest = max(longest, pause)
+	}
+	return cycles, total, longest
+}
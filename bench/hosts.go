@@ -0,0 +1,69 @@
+package bench
+
+import (
+	"context"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// A host is flagged when its attempt error rate is at least
+// hostFlagMinPct and hostFlagRatio times that of all other hosts combined.
+const (
+	hostFlagMinPct = 1.0
+	hostFlagRatio  = 3.0
+)
+
+// hostObserver records the outcome of every query attempt per coordinator,
+// including attempts gocql retried on the next host with -num-retries, so
+// a failing node stands out from the rest. It forwards every observation
+// to next, if set.
+type hostObserver struct {
+	stats *runStats
+	next  gocql.QueryObserver
+}
+
+func (o hostObserver) ObserveQuery(ctx context.Context, q gocql.ObservedQuery) {
+	if q.Host != nil {
+		o.stats.recordHost(q.Host.ConnectAddress().String(), q.Host.DataCenter(), q.Err, q.End.Sub(q.Start))
+	}
+	if o.next != nil {
+		o.next.ObserveQuery(ctx, q)
+	}
+}
+
+// hostStats accumulates the attempts one coordinator served.
+type hostStats struct {
+	dc       string
+	attempts int64
+	errors   int64
+	timeouts int64
+	total    time.Duration
+}
+
+// hostResults summarizes hosts sorted by address and flags the ones whose
+// error rate is out of line with the rest.
+func hostResults(hosts map[string]*hostStats) []HostResult {
+	var attempts, errs int64
+	for _, h := range hosts {
+		attempts += h.attempts
+		errs += h.errors
+	}
+	out := make([]HostResult, 0, len(hosts))
+	for _, addr := range sortedKeys(hosts) {
+		h := hosts[addr]
+		r := HostResult{
+			Host:         addr,
+			DC:           h.dc,
+			Attempts:     h.attempts,
+			Errors:       h.errors,
+			Timeouts:     h.timeouts,
+			ErrorRatePct: percent(h.errors, h.attempts),
+			AvgLatencyMs: avgMs(h.total, h.attempts),
+		}
+		others := percent(errs-h.errors, attempts-h.attempts)
+		r.Flagged = r.ErrorRatePct >= hostFlagMinPct && r.ErrorRatePct >= hostFlagRatio*others
+		out = append(out, r)
+	}
+	return out
+}
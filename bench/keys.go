@@ -0,0 +1,347 @@
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// QueryKey represents a primary key for a row in the test_table.
+type QueryKey struct {
+	EqpModel     string `json:"eqp_model"`
+	StrategyName string `json:"strtgy_name"`
+	JobID        string `json:"job_id"`
+
+	// Bucket is an optional day bucket (YYYY-MM-DD) for time-series tables.
+	// Keys without one get a bucket from -date-range.
+	Bucket string `json:"bucket,omitempty"`
+
+	// typed holds the key fields converted by -key-types, in bind order.
+	// For a lean point read it is filled in for every key, with the plain
+	// strings when there are no -key-types.
+	typed []interface{}
+}
+
+// StrictKeyJSON turns off the number coercion in QueryKey.UnmarshalJSON
+// (-strict-key-json). Set it before any keys are decoded; it applies to
+// every run in the process.
+var StrictKeyJSON bool
+
+// keyCoercions counts key fields decoded from a JSON number, since the last
+// reportKeyCoercions.
+var keyCoercions atomic.Int64
+
+// keyStringFields are the QueryKey fields the coercion applies to.
+var keyStringFields = map[string]bool{"eqp_model": true, "strtgy_name": true, "job_id": true, "bucket": true}
+
+// UnmarshalJSON decodes a key, accepting a JSON number where a string field
+// is expected and keeping the number's literal text, so generators that
+// write job_id unquoted still produce a usable keys file. Records that
+// decode as they are take the plain path.
+func (k *QueryKey) UnmarshalJSON(data []byte) error {
+	type queryKey QueryKey
+	err := json.Unmarshal(data, (*queryKey)(k))
+	var typeErr *json.UnmarshalTypeError
+	if err == nil || StrictKeyJSON || !errors.As(err, &typeErr) {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		return err
+	}
+	coerced := 0
+	for name, v := range fields {
+		if keyStringFields[name] && len(v) > 0 && (v[0] == '-' || (v[0] >= '0' && v[0] <= '9')) {
+			fields[name], _ = json.Marshal(string(v))
+			coerced++
+		}
+	}
+	if coerced == 0 {
+		return err
+	}
+	fixed, mErr := json.Marshal(fields)
+	if mErr != nil {
+		return err
+	}
+	*k = QueryKey{}
+	if err := json.Unmarshal(fixed, (*queryKey)(k)); err != nil {
+		return err
+	}
+	keyCoercions.Add(int64(coerced))
+	return nil
+}
+
+// reportKeyCoercions logs to logger how many key fields UnmarshalJSON
+// coerced from numbers since the last call.
+func reportKeyCoercions(logger *log.Logger) {
+	if n := keyCoercions.Swap(0); n > 0 {
+		logger.Printf("Coerced %d numeric key fields to strings; pass -strict-key-json to reject them instead.", n)
+	}
+}
+
+// rawValues returns the key fields as strings, in bind order.
+func (k QueryKey) rawValues() []string {
+	return []string{k.EqpModel, k.JobID, k.StrategyName}
+}
+
+// bindValues returns the values bound for the key, in bind order: the
+// -key-types conversions when set, the plain strings otherwise.
+func (k QueryKey) bindValues() []interface{} {
+	if k.typed != nil {
+		return append([]interface{}(nil), k.typed...)
+	}
+	return []interface{}{k.EqpModel, k.JobID, k.StrategyName}
+}
+
+// boxKeyValues fills in typed for keys that have no -key-types conversion,
+// boxing each string once at load time instead of once per query. It costs
+// about a hundred bytes per key, so only the lean point read uses it.
+func boxKeyValues(keys []QueryKey) {
+	for i := range keys {
+		if keys[i].typed == nil {
+			k := &keys[i]
+			k.typed = []interface{}{k.EqpModel, k.JobID, k.StrategyName}
+		}
+	}
+}
+
+// groupInList merges the keys that share a partition, first clustering
+// column and bucket into -in-list keys carrying up to size strtgy_name
+// values each, in the order the groups first appear in the keys file. The
+// list is the third bind value, converted by -key-types if set; the
+// merged key's StrategyName joins the names with commas for display.
+func groupInList(keys []QueryKey, size int) []QueryKey {
+	type group struct{ eqpModel, jobID, bucket string }
+	var order []group
+	members := make(map[group][]QueryKey)
+	for _, k := range keys {
+		g := group{k.EqpModel, k.JobID, k.Bucket}
+		if _, ok := members[g]; !ok {
+			order = append(order, g)
+		}
+		members[g] = append(members[g], k)
+	}
+
+	var grouped []QueryKey
+	for _, g := range order {
+		ks := members[g]
+		for start := 0; start < len(ks); start += size {
+			chunk := ks[start:min(start+size, len(ks))]
+			names := make([]string, len(chunk))
+			list := make([]interface{}, len(chunk))
+			for i, k := range chunk {
+				names[i] = k.StrategyName
+				list[i] = k.StrategyName
+				if k.typed != nil {
+					list[i] = k.typed[2]
+				}
+			}
+			merged := QueryKey{EqpModel: g.eqpModel, JobID: g.jobID, Bucket: g.bucket, StrategyName: strings.Join(names, ",")}
+			first := chunk[0].bindValues()
+			merged.typed = []interface{}{first[0], first[1], list}
+			grouped = append(grouped, merged)
+		}
+	}
+	return grouped
+}
+
+// partitionOf names the partition key points at: its eqp_model, and its
+// day bucket when it carries one.
+func partitionOf(eqpModel, bucket string) string {
+	if bucket == "" {
+		return eqpModel
+	}
+	return eqpModel + "/" + bucket
+}
+
+// limitPartitions keeps only the keys in the first n distinct partitions,
+// in file order, for -max-partitions. It also returns how many distinct
+// partitions keys had.
+func limitPartitions(keys []QueryKey, n int) ([]QueryKey, int) {
+	order := make(map[string]int)
+	var kept []QueryKey
+	for _, k := range keys {
+		p := partitionOf(k.EqpModel, k.Bucket)
+		i, ok := order[p]
+		if !ok {
+			i = len(order)
+			order[p] = i
+		}
+		if i < n {
+			kept = append(kept, k)
+		}
+	}
+	return kept, len(order)
+}
+
+// parseKeys decodes the contents of a keys file. A file whose first
+// non-whitespace byte is '[' is treated as a JSON array; anything else is
+// treated as NDJSON with one QueryKey object per line.
+//
+// With bestEffort set, NDJSON lines that fail to parse are skipped instead of
+// aborting the load, and a truncated JSON array yields the entries decoded
+// before the damage. Skipped entries are logged to logger, and their number
+// is returned either way.
+func parseKeys(data []byte, bestEffort bool, logger *log.Logger) ([]QueryKey, int, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return parseKeysArray(trimmed, bestEffort, logger)
+	}
+	// Untrimmed, so line numbers count any leading blank lines.
+	return parseKeysNDJSON(data, bestEffort, logger)
+}
+
+func parseKeysArray(data []byte, bestEffort bool, logger *log.Logger) ([]QueryKey, int, error) {
+	var keys []QueryKey
+	err := json.Unmarshal(data, &keys)
+	if err == nil || !bestEffort {
+		return keys, 0, err
+	}
+
+	// Decode element by element so a bad entry only costs itself. A syntax
+	// error leaves the decoder unable to find the next element, so only a
+	// truncated or garbled tail ends the load early.
+	keys = nil
+	skipped := 0
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return nil, 0, err
+	}
+	for i := 1; dec.More(); i++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			logger.Printf("Skipping the keys from key %d on, which do not parse: %v", i, err)
+			return keys, skipped + 1, nil
+		}
+		var key QueryKey
+		if err := json.Unmarshal(raw, &key); err != nil {
+			logger.Printf("Skipping unparseable key %d: %v", i, err)
+			skipped++
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, skipped, nil
+}
+
+func parseKeysNDJSON(data []byte, bestEffort bool, logger *log.Logger) ([]QueryKey, int, error) {
+	var keys []QueryKey
+	skipped := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var key QueryKey
+		if err := json.Unmarshal(line, &key); err != nil {
+			if !bestEffort {
+				return nil, 0, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			logger.Printf("Skipping unparseable key on line %d: %v", lineNo, err)
+			skipped++
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return keys, skipped, err
+	}
+	return keys, skipped, nil
+}
+
+// validateKeyFields checks the field names of every record in a keys file
+// against the bind fields, so a file whose records say "task_id" where the
+// statement binds job_id fails before the run instead of binding empty
+// strings. extra lists further fields the file may carry, such as a replay
+// log's "ts". An array that does not parse is an error unless bestEffort is
+// set, when its unparseable elements are left to parseKeys to skip, as
+// NDJSON lines that do not parse always are. Records are named as parseKeys
+// names them: the 1-based key of an array, the file line of NDJSON.
+func validateKeyFields(data []byte, bestEffort bool, extra ...string) error {
+	known := map[string]bool{"bucket": true}
+	for _, f := range keyFields {
+		known[f] = true
+	}
+	for _, f := range extra {
+		known[f] = true
+	}
+
+	type keyRecord struct {
+		name   string
+		fields map[string]json.RawMessage
+	}
+	var records []keyRecord
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' && !bestEffort {
+		var fields []map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &fields); err != nil {
+			return fmt.Errorf("keys file is not a valid JSON array of objects: %w", err)
+		}
+		for i, f := range fields {
+			records = append(records, keyRecord{fmt.Sprintf("key %d", i+1), f})
+		}
+	} else if len(trimmed) > 0 && trimmed[0] == '[' {
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		if _, err := dec.Token(); err != nil {
+			return fmt.Errorf("keys file is not a valid JSON array of objects: %w", err)
+		}
+		// As in parseKeysArray, a syntax error ends the array.
+		for i := 1; dec.More(); i++ {
+			var raw json.RawMessage
+			if dec.Decode(&raw) != nil {
+				break
+			}
+			var fields map[string]json.RawMessage
+			if json.Unmarshal(raw, &fields) == nil {
+				records = append(records, keyRecord{fmt.Sprintf("key %d", i), fields})
+			}
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for lineNo := 1; scanner.Scan(); lineNo++ {
+			var fields map[string]json.RawMessage
+			if json.Unmarshal(scanner.Bytes(), &fields) == nil {
+				records = append(records, keyRecord{fmt.Sprintf("line %d", lineNo), fields})
+			}
+		}
+	}
+
+	for _, record := range records {
+		var unknown, missing []string
+		for f := range record.fields {
+			if !known[f] {
+				unknown = append(unknown, f)
+			}
+		}
+		for _, f := range keyFields {
+			if _, ok := record.fields[f]; !ok {
+				missing = append(missing, f)
+			}
+		}
+		if len(unknown) == 0 && len(missing) == 0 {
+			continue
+		}
+		sort.Strings(unknown)
+		var problems []string
+		if len(unknown) > 0 {
+			problems = append(problems, "fields not bound to any column: "+strings.Join(unknown, ", "))
+		}
+		if len(missing) > 0 {
+			problems = append(problems, "missing bound fields: "+strings.Join(missing, ", "))
+		}
+		return fmt.Errorf("%s has %s (bound fields are %s)", record.name, strings.Join(problems, "; "), strings.Join(keyFields, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package bench
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureLog returns what f logs to the logger it is passed.
+func captureLog(f func(logger *log.Logger)) string {
+	var buf bytes.Buffer
+	f(log.New(&buf, "", 0))
+	return buf.String()
+}
+
+func TestBestEffortSkipNumbering(t *testing.T) {
+	good := `{"eqp_model": "m", "job_id": "j", "strtgy_name": "s"}`
+	files := []struct {
+		name, body, want string
+	}{
+		{"keys.json", "[" + good + `, "oops", ` + good + "]", "Skipping unparseable key 2:"},
+		{"keys.ndjson", "\n" + good + "\n\n\"oops\"\n" + good + "\n", "Skipping unparseable key on line 4:"},
+	}
+	for _, f := range files {
+		path := filepath.Join(t.TempDir(), f.name)
+		if err := os.WriteFile(path, []byte(f.body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		loaded := captureLog(func(logger *log.Logger) {
+			if keys, skipped, err := parseKeys([]byte(f.body), true, logger); err != nil || len(keys) != 2 || skipped != 1 {
+				t.Errorf("%s: parseKeys = %d keys, %d skipped, %v, want 2, 1 and no error", f.name, len(keys), skipped, err)
+			}
+		})
+		streamed := captureLog(func(logger *log.Logger) {
+			c, err := openKeyChunker(path, true, logger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			if keys, err := c.next(10); err != nil || len(keys) != 2 || c.skipped != 1 {
+				t.Errorf("%s: keyChunker.next = %d keys, %d skipped, %v, want 2, 1 and no error", f.name, len(keys), c.skipped, err)
+			}
+		})
+		for how, logged := range map[string]string{"loaded": loaded, "streamed": streamed} {
+			if !strings.Contains(logged, f.want) {
+				t.Errorf("%s %s: logged %q, want %q", f.name, how, logged, f.want)
+			}
+		}
+	}
+}
+
+func TestValidateKeyFields(t *testing.T) {
+	good := `{"eqp_model": "m", "job_id": "j", "strtgy_name": "s"}`
+	bad := `{"eqp_model": "m", "task_id": "j", "strtgy_name": "s"}`
+	tests := []struct {
+		name       string
+		body       string
+		bestEffort bool
+		want       string // a fragment of the error, or "" for none
+	}{
+		{"array", "[" + good + ", " + bad + "]", false, "key 2 has fields not bound to any column: task_id"},
+		{"unparseable array", "[" + good + `, "oops"]`, false, "not a valid JSON array of objects"},
+		{"unparseable array, best effort", "[" + good + `, "oops", ` + good + "]", true, ""},
+		{"unparseable array, best effort, bad record", "[" + good + `, "oops", ` + bad + "]", true, "key 3 has"},
+		{"ndjson", "\n" + good + "\n\"oops\"\n\n" + bad + "\n", false, "line 5 has"},
+	}
+	for _, tt := range tests {
+		err := validateKeyFields([]byte(tt.body), tt.bestEffort)
+		if tt.want == "" && err != nil {
+			t.Errorf("%s: validateKeyFields = %v, want no error", tt.name, err)
+		}
+		if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%s: validateKeyFields = %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package bench
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// keyFields names the key columns in bind order, which is the order
+// -key-types maps its entries to.
+var keyFields = []string{"eqp_model", "job_id", "strtgy_name"}
+
+// keyConverter turns a key's string form into the Go value gocql marshals
+// for one CQL type.
+type keyConverter func(s string) (interface{}, error)
+
+var keyConverters = map[string]keyConverter{
+	"text":    func(s string) (interface{}, error) { return s, nil },
+	"varchar": func(s string) (interface{}, error) { return s, nil },
+	"ascii":   func(s string) (interface{}, error) { return s, nil },
+	"int": func(s string) (interface{}, error) {
+		n, err := strconv.ParseInt(s, 10, 32)
+		return int32(n), err
+	},
+	"bigint": func(s string) (interface{}, error) { return strconv.ParseInt(s, 10, 64) },
+	"smallint": func(s string) (interface{}, error) {
+		n, err := strconv.ParseInt(s, 10, 16)
+		return int16(n), err
+	},
+	"tinyint": func(s string) (interface{}, error) {
+		n, err := strconv.ParseInt(s, 10, 8)
+		return int8(n), err
+	},
+	"boolean": func(s string) (interface{}, error) { return strconv.ParseBool(s) },
+	"float": func(s string) (interface{}, error) {
+		f, err := strconv.ParseFloat(s, 32)
+		return float32(f), err
+	},
+	"double":    func(s string) (interface{}, error) { return strconv.ParseFloat(s, 64) },
+	"uuid":      func(s string) (interface{}, error) { return gocql.ParseUUID(s) },
+	"timeuuid":  func(s string) (interface{}, error) { return gocql.ParseUUID(s) },
+	"timestamp": func(s string) (interface{}, error) { return time.Parse(time.RFC3339Nano, s) },
+}
+
+// ParseKeyTypes parses a -key-types value such as "text,uuid,int". Entries
+// map positionally to keyFields; fields without an entry stay text.
+func ParseKeyTypes(s string) ([]string, error) {
+	types := SplitList(s)
+	if len(types) > len(keyFields) {
+		return nil, fmt.Errorf("got %d types for %d key fields (%s)", len(types), len(keyFields), strings.Join(keyFields, ", "))
+	}
+	for i, t := range types {
+		t = strings.ToLower(t)
+		if _, ok := keyConverters[t]; !ok {
+			return nil, fmt.Errorf("unsupported type %q for %s", t, keyFields[i])
+		}
+		types[i] = t
+	}
+	return types, nil
+}
+
+// applyKeyTypes converts every key's fields to the typed values they are
+// bound with. Conversion happens once at load time so a bad key fails
+// before the run instead of as a server-side type error mid-run.
+func applyKeyTypes(keys []QueryKey, types []string) error {
+	if len(types) == 0 {
+		return nil
+	}
+	for i := range keys {
+		raw := keys[i].rawValues()
+		typed := make([]interface{}, len(raw))
+		for j, s := range raw {
+			if j >= len(types) {
+				typed[j] = s
+				continue
+			}
+			v, err := keyConverters[types[j]](s)
+			if err != nil {
+				return fmt.Errorf("key %d: %s %q is not a valid %s: %w", i+1, keyFields[j], s, types[j], err)
+			}
+			typed[j] = v
+		}
+		keys[i].typed = typed
+	}
+	return nil
+}
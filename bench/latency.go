@@ -0,0 +1,110 @@
+package bench
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// Latencies are recorded in microseconds between 1µs and one minute with
+// three significant digits.
+const (
+	minLatencyMicros = 1
+	maxLatencyMicros = int64(time.Minute / time.Microsecond)
+	latencySigFigs   = 3
+)
+
+// latencyCollector records per-query latencies into a histogram on its own
+// goroutine. Workers hand samples over a buffered channel and never block:
+// when the buffer is full the sample is dropped and counted instead, so a
+// slow collector cannot inflate the latencies it is measuring.
+type latencyCollector struct {
+	samples chan time.Duration
+	done    chan struct{}
+	dropped atomic.Int64
+
+	mu   sync.Mutex
+	hist *hdrhistogram.Histogram
+}
+
+func newLatencyCollector(buffer int) *latencyCollector {
+	c := &latencyCollector{
+		samples: make(chan time.Duration, buffer),
+		done:    make(chan struct{}),
+		hist:    hdrhistogram.New(minLatencyMicros, maxLatencyMicros, latencySigFigs),
+	}
+	go func() {
+		defer close(c.done)
+		for d := range c.samples {
+			c.mu.Lock()
+			c.hist.RecordValue(clampMicros(d))
+			c.mu.Unlock()
+		}
+	}()
+	return c
+}
+
+// Add submits a sample without blocking.
+func (c *latencyCollector) Add(d time.Duration) {
+	select {
+	case c.samples <- d:
+	default:
+		c.dropped.Add(1)
+	}
+}
+
+// Close stops accepting samples and waits for the buffered ones to be
+// recorded. Add must not be called afterwards.
+func (c *latencyCollector) Close() {
+	close(c.samples)
+	<-c.done
+}
+
+// Fill copies the histogram percentiles and the dropped-sample count into r.
+// It is safe to call while samples are still being recorded.
+func (c *latencyCollector) Fill(r *Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r.P50Ms = microsToMs(c.hist.ValueAtQuantile(50))
+	r.P90Ms = microsToMs(c.hist.ValueAtQuantile(90))
+	r.P99Ms = microsToMs(c.hist.ValueAtQuantile(99))
+	r.MaxMs = microsToMs(c.hist.Max())
+	r.DroppedSamples = c.dropped.Load()
+}
+
+// Histogram returns a copy of the latency histogram, in microseconds.
+func (c *latencyCollector) Histogram() *hdrhistogram.Histogram {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return hdrhistogram.Import(c.hist.Export())
+}
+
+// WriteHgrm writes the latency histogram of r (in microseconds) as an
+// HdrHistogram percentile distribution in milliseconds, the .hgrm format
+// the HdrHistogram plotting tools read, for -hgrm-file. A Result decoded
+// from JSON has no histogram and writes nothing.
+func (r Result) WriteHgrm(w io.Writer) error {
+	if r.latencyHist == nil {
+		return nil
+	}
+	_, err := r.latencyHist.PercentilesPrint(w, 5, 1000)
+	return err
+}
+
+func clampMicros(d time.Duration) int64 {
+	us := int64(d / time.Microsecond)
+	if us < minLatencyMicros {
+		return minLatencyMicros
+	}
+	if us > maxLatencyMicros {
+		return maxLatencyMicros
+	}
+	return us
+}
+
+func microsToMs(us int64) float64 {
+	return float64(us) / 1000
+}
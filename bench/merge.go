@@ -0,0 +1,237 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// PostResult sends a worker's Result to the coordinator. The result must
+// carry its encoded latency histogram so the coordinator can merge
+// percentiles rather than average them.
+func PostResult(url string, r Result) error {
+	if r.latencyHist != nil {
+		encoded, err := r.latencyHist.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+		if err != nil {
+			return fmt.Errorf("encoding latency histogram: %w", err)
+		}
+		r.LatencyHistogram = string(encoded)
+	}
+	return postJSON(url, r)
+}
+
+// postJSON POSTs v as JSON to url and fails on a non-2xx response.
+func postJSON(url string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+// MergeResults combines results of runs that ran side by side, such as
+// coordinator workers. Counts and throughput are summed, the wall time is
+// the longest run's, means are weighted by completed queries and
+// percentiles come from the merged latency histograms. Results are also
+// broken down by -tag, and the merged tag lists the distinct tags. Results
+// without a usable latency histogram are logged to logger, which may be
+// nil.
+func MergeResults(results []Result, logger *log.Logger) Result {
+	logger = orDiscard(logger)
+	var m Result
+	var latencyTotal float64
+	hist := hdrhistogram.New(minLatencyMicros, maxLatencyMicros, latencySigFigs)
+	groups := make(map[string]map[string]*GroupResult)
+	groupLatency := make(map[*GroupResult]float64)
+
+	var tags []string
+	for i, r := range results {
+		if !slices.Contains(tags, r.Tag) {
+			tags = append(tags, r.Tag)
+		}
+		addTag(groups, groupLatency, r)
+		m.Concurrency += r.Concurrency
+		m.Queries += r.Queries
+		m.CompletedQueries += r.CompletedQueries
+		m.SuccessfulQueries += r.SuccessfulQueries
+		m.FailedQueries += r.FailedQueries
+		m.DeadlineExceeded += r.DeadlineExceeded
+		m.ServerTimeouts += r.ServerTimeouts
+		m.DroppedSamples += r.DroppedSamples
+		m.Panics += r.Panics
+		// Workers run side by side, so their byte rates add up like QPS.
+		m.BytesRead += r.BytesRead
+		m.BytesReadPerSecond += r.BytesReadPerSecond
+		m.BytesWritten += r.BytesWritten
+		m.BytesWrittenPerSecond += r.BytesWrittenPerSecond
+		// Workers may share partitions, so the largest count is the
+		// lower bound of the union.
+		m.PartitionsQueried = max(m.PartitionsQueried, r.PartitionsQueried)
+		m.QPS += r.QPS
+		m.TargetRate += r.TargetRate
+		m.AvgInFlight += r.AvgInFlight
+		m.GCCycles += r.GCCycles
+		m.GCPauseMs += r.GCPauseMs
+		m.GCMaxPauseMs = max(m.GCMaxPauseMs, r.GCMaxPauseMs)
+		m.StoppedEarly = m.StoppedEarly || r.StoppedEarly
+		if r.TotalSeconds > m.TotalSeconds {
+			m.TotalSeconds = r.TotalSeconds
+		}
+		latencyTotal += r.AvgLatencyMs * float64(r.CompletedQueries)
+
+		if r.latencyHist != nil {
+			hist.Merge(r.latencyHist)
+		} else if r.LatencyHistogram == "" {
+			logger.Printf("WARNING: result %d has no latency histogram; its latencies are left out of the merged percentiles.", i+1)
+		} else if h, err := hdrhistogram.Decode([]byte(r.LatencyHistogram)); err != nil {
+			logger.Printf("WARNING: result %d has an unreadable latency histogram: %v", i+1, err)
+		} else {
+			hist.Merge(h)
+		}
+
+		for dim, gs := range r.Breakdowns {
+			byName := groups[dim]
+			if byName == nil {
+				byName = make(map[string]*GroupResult)
+				groups[dim] = byName
+			}
+			for _, g := range gs {
+				mg := byName[g.Name]
+				if mg == nil {
+					mg = &GroupResult{Name: g.Name}
+					byName[g.Name] = mg
+				}
+				mg.Queries += g.Queries
+				mg.SuccessfulQueries += g.SuccessfulQueries
+				mg.FailedQueries += g.FailedQueries
+				groupLatency[mg] += g.AvgLatencyMs * float64(g.Queries)
+			}
+		}
+	}
+
+	if len(tags) == 1 {
+		// Every result carries the same tag (or none): no breakdown.
+		m.Tag = tags[0]
+		delete(groups, "tag")
+	} else {
+		slices.Sort(tags)
+		m.Tag = strings.Join(tags, ",")
+	}
+	m.HitRatePct = percent(m.SuccessfulQueries, m.CompletedQueries)
+	m.TimeoutRatePct = percent(m.DeadlineExceeded, m.CompletedQueries)
+	if m.CompletedQueries > 0 {
+		m.AvgLatencyMs = latencyTotal / float64(m.CompletedQueries)
+		m.BytesReadPerQuery = float64(m.BytesRead) / float64(m.CompletedQueries)
+	}
+	m.P50Ms = microsToMs(hist.ValueAtQuantile(50))
+	m.P90Ms = microsToMs(hist.ValueAtQuantile(90))
+	m.P99Ms = microsToMs(hist.ValueAtQuantile(99))
+	m.MaxMs = microsToMs(hist.Max())
+	m.latencyHist = hist
+
+	for dim, byName := range groups {
+		if m.Breakdowns == nil {
+			m.Breakdowns = make(map[string][]GroupResult)
+		}
+		var out []GroupResult
+		for _, g := range byName {
+			g.SharePct = percent(g.Queries, m.CompletedQueries)
+			g.HitRatePct = percent(g.SuccessfulQueries, g.Queries)
+			if g.Queries > 0 {
+				g.AvgLatencyMs = groupLatency[g] / float64(g.Queries)
+			}
+			out = append(out, *g)
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+		m.Breakdowns[dim] = out
+	}
+	return m
+}
+
+// mergeSequential combines passes of one run that ran one after another,
+// such as -chunk-size chunks or -phases. Unlike MergeResults it merges the
+// passes' raw counters, so every field of the summary covers all of them:
+// percentiles, distributions and per-host or per-group figures are exact
+// rather than recombined from the passes' summaries. The wall time is the
+// sum of the passes', and throughput is over that sum.
+func mergeSequential(cfg Config, results []Result) Result {
+	var stats runStats
+	hist := hdrhistogram.New(minLatencyMicros, maxLatencyMicros, latencySigFigs)
+	cfg.NumQueries = 0
+	for _, r := range results {
+		cfg.NumQueries += r.Queries
+		if r.stats != nil {
+			stats.merge(r.stats, cfg.SampleErrors)
+		}
+		if r.latencyHist != nil {
+			hist.Merge(r.latencyHist)
+		}
+	}
+
+	m := stats.result(cfg)
+	m.TargetRate = cfg.Rate
+	for _, r := range results {
+		m.TotalSeconds += r.TotalSeconds
+		m.AvgInFlight += r.AvgInFlight * r.TotalSeconds
+		m.DroppedSamples += r.DroppedSamples
+		m.GCCycles += r.GCCycles
+		m.GCPauseMs += r.GCPauseMs
+		m.GCMaxPauseMs = max(m.GCMaxPauseMs, r.GCMaxPauseMs)
+		m.StoppedEarly = m.StoppedEarly || r.StoppedEarly
+	}
+	if m.TotalSeconds > 0 {
+		m.QPS = float64(m.CompletedQueries) / m.TotalSeconds
+		m.AvgInFlight /= m.TotalSeconds
+		if cfg.MeasureBytes {
+			m.BytesReadPerSecond = float64(m.BytesRead) / m.TotalSeconds
+			m.BytesWrittenPerSecond = float64(m.BytesWritten) / m.TotalSeconds
+		}
+	}
+	m.P50Ms = microsToMs(hist.ValueAtQuantile(50))
+	m.P90Ms = microsToMs(hist.ValueAtQuantile(90))
+	m.P99Ms = microsToMs(hist.ValueAtQuantile(99))
+	m.MaxMs = microsToMs(hist.Max())
+	m.latencyHist = hist
+	m.stats = &stats
+	return m
+}
+
+// addTag adds r to the "tag" breakdown of the merged groups, as a group
+// named after its -tag.
+func addTag(groups map[string]map[string]*GroupResult, groupLatency map[*GroupResult]float64, r Result) {
+	byName := groups["tag"]
+	if byName == nil {
+		byName = make(map[string]*GroupResult)
+		groups["tag"] = byName
+	}
+	name := r.Tag
+	if name == "" {
+		name = "(untagged)"
+	}
+	g := byName[name]
+	if g == nil {
+		g = &GroupResult{Name: name}
+		byName[name] = g
+	}
+	g.Queries += r.CompletedQueries
+	g.SuccessfulQueries += r.SuccessfulQueries
+	g.FailedQueries += r.FailedQueries
+	groupLatency[g] += r.AvgLatencyMs * float64(r.CompletedQueries)
+}
@@ -0,0 +1,110 @@
+package bench
+
+import (
+	"time"
+)
+
+// recordMetadata adds the WRITETIME and TTL a -metadata-column read
+// returned. WRITETIME is in microseconds since the epoch and NULL when the
+// cell is; TTL is the remaining seconds and NULL when the cell has none.
+func recordMetadata(stats *runStats, dest []interface{}, window time.Duration) {
+	if len(dest) < 2 || isNull(dest[0]) {
+		return
+	}
+	wt, ok := dest[0].(*nullable)
+	if !ok {
+		return
+	}
+	writetime, ok := wt.Value.(*int64)
+	if !ok {
+		return
+	}
+	age := time.Since(time.UnixMicro(*writetime))
+	remaining := time.Duration(-1)
+	if n, ok := dest[1].(*nullable); ok && !n.null {
+		if ttl, ok := n.Value.(*int); ok {
+			remaining = time.Duration(*ttl) * time.Second
+		}
+	}
+	stats.recordMetadata(age, remaining, window)
+}
+
+// metadataStats aggregates -metadata-column reads.
+type metadataStats struct {
+	cells      int64
+	ageMin     time.Duration
+	ageMax     time.Duration
+	ageTotal   time.Duration
+	withTTL    int64
+	ttlMin     time.Duration
+	ttlTotal   time.Duration
+	nearExpiry int64
+}
+
+// recordMetadata adds one cell's age and remaining TTL; remaining is
+// negative for a cell without a TTL.
+func (s *runStats) recordMetadata(age, remaining, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := &s.metadata
+	if m.cells == 0 || age < m.ageMin {
+		m.ageMin = age
+	}
+	if age > m.ageMax {
+		m.ageMax = age
+	}
+	m.cells++
+	m.ageTotal += age
+	if remaining < 0 {
+		return
+	}
+	if m.withTTL == 0 || remaining < m.ttlMin {
+		m.ttlMin = remaining
+	}
+	m.withTTL++
+	m.ttlTotal += remaining
+	if remaining <= window {
+		m.nearExpiry++
+	}
+}
+
+// merge adds the cells of o.
+func (m *metadataStats) merge(o metadataStats) {
+	if o.cells == 0 {
+		return
+	}
+	if m.cells == 0 || o.ageMin < m.ageMin {
+		m.ageMin = o.ageMin
+	}
+	m.ageMax = max(m.ageMax, o.ageMax)
+	m.cells += o.cells
+	m.ageTotal += o.ageTotal
+	if o.withTTL > 0 && (m.withTTL == 0 || o.ttlMin < m.ttlMin) {
+		m.ttlMin = o.ttlMin
+	}
+	m.withTTL += o.withTTL
+	m.ttlTotal += o.ttlTotal
+	m.nearExpiry += o.nearExpiry
+}
+
+// result summarizes the cells, or returns nil if none were read.
+func (m metadataStats) result(column string, window time.Duration) *MetadataResult {
+	if m.cells == 0 {
+		return nil
+	}
+	r := &MetadataResult{
+		Column:        column,
+		Cells:         m.cells,
+		AgeMinHours:   m.ageMin.Hours(),
+		AgeAvgHours:   (m.ageTotal / time.Duration(m.cells)).Hours(),
+		AgeMaxHours:   m.ageMax.Hours(),
+		WithTTLPct:    percent(m.withTTL, m.cells),
+		ExpiryWindowH: window.Hours(),
+		NearExpiryPct: percent(m.nearExpiry, m.cells),
+	}
+	if m.withTTL > 0 {
+		r.TTLMinHours = m.ttlMin.Hours()
+		r.TTLAvgHours = (m.ttlTotal / time.Duration(m.withTTL)).Hours()
+	}
+	return r
+}
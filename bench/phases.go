@@ -0,0 +1,135 @@
+package bench
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// insertTemplate is the write issued for every key of a -phases write
+// phase; the %s is the table.
+const insertTemplate = "INSERT INTO %s (eqp_model, job_id, strtgy_name) VALUES (?, ?, ?)"
+
+// insertStmt returns the write cfg issues against table. -bucket-column
+// adds the key's time bucket as a fourth column.
+func insertStmt(table string, cfg Config) string {
+	if cfg.BucketColumn != "" {
+		return fmt.Sprintf("INSERT INTO %s (eqp_model, job_id, strtgy_name, %s) VALUES (?, ?, ?, ?)", table, cfg.BucketColumn)
+	}
+	return fmt.Sprintf(insertTemplate, table)
+}
+
+// Phase is one entry of a -phases spec.
+type Phase struct {
+	Op      string
+	Queries int
+}
+
+// ParsePhases parses a spec such as "write:100000,read:100000": an
+// operation, read or write, and its query count, per phase.
+func ParsePhases(spec string) ([]Phase, error) {
+	var phases []Phase
+	for _, part := range SplitList(spec) {
+		op, countStr, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("expected read:COUNT or write:COUNT, got %q", part)
+		}
+		op = strings.ToLower(strings.TrimSpace(op))
+		if op != "read" && op != "write" {
+			return nil, fmt.Errorf("unknown operation %q; use read or write", op)
+		}
+		n, err := ParseCount(strings.TrimSpace(countStr))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid query count %q for %s", countStr, op)
+		}
+		phases = append(phases, Phase{Op: op, Queries: n})
+	}
+	if len(phases) == 0 {
+		return nil, fmt.Errorf("no phases in %q", spec)
+	}
+	return phases, nil
+}
+
+// phaseKeys generates the keys written by -phases when no keys file is
+// given: one per query of the largest write phase, unique to this run so
+// the read phase cannot find rows left over from an earlier one.
+func phaseKeys(cfg Config) ([]QueryKey, error) {
+	n := 0
+	for _, p := range cfg.Phases {
+		if p.Op == "write" && p.Queries > n {
+			n = p.Queries
+		}
+	}
+	runID := strconv.FormatInt(time.Now().UnixNano(), 36)
+	keys := make([]QueryKey, n)
+	for i := range keys {
+		keys[i] = QueryKey{
+			EqpModel:     fmt.Sprintf("phases-%s-%d", runID, i/100),
+			JobID:        strconv.Itoa(i),
+			StrategyName: strconv.Itoa(i % 7),
+		}
+	}
+	cfg.printf("Generated %d keys for -phases (eqp_model prefix phases-%s)\n", n, runID)
+	if cfg.MaxPartitions > 0 {
+		keys, _ = limitPartitions(keys, cfg.MaxPartitions)
+	}
+	if err := applyKeyTypes(keys, cfg.KeyTypes); err != nil {
+		return nil, fmt.Errorf("converting keys with -key-types: %w", err)
+	}
+	if cfg.LeanPointRead() {
+		boxKeyValues(keys)
+	}
+	return keys, nil
+}
+
+// runPhases runs the -phases passes in order. A read phase after a write
+// phase reads only the keys written so far, so it measures exactly the
+// rows the run just inserted. The caller gets the phases merged into one
+// combined result with every phase attached.
+func (b *benchmark) runPhases() Result {
+	all := b.keys
+	defer func() { b.keys = all }()
+
+	var results []Result
+	var summaries []PhaseResult
+	written := 0
+	for i, p := range b.cfg.Phases {
+		b.keys = all
+		if p.Op == "write" {
+			written = max(written, min(p.Queries, len(all)))
+			b.keys = all[:min(p.Queries, len(all))]
+		} else if written > 0 {
+			b.keys = all[:written]
+		}
+		b.cfg.printf("=== Phase %d/%d: %s %d queries over %d keys ===\n", i+1, len(b.cfg.Phases), p.Op, p.Queries, len(b.keys))
+		result := b.run(runOptions{Queries: p.Queries, Insert: p.Op == "write"})
+		s := PhaseResult{
+			Phase:         i + 1,
+			Op:            p.Op,
+			Keys:          len(b.keys),
+			Queries:       result.CompletedQueries,
+			FailedQueries: result.FailedQueries,
+			HitRatePct:    result.HitRatePct,
+			TotalSeconds:  result.TotalSeconds,
+			QPS:           result.QPS,
+			AvgLatencyMs:  result.AvgLatencyMs,
+			P50Ms:         result.P50Ms,
+			P99Ms:         result.P99Ms,
+			MaxMs:         result.MaxMs,
+		}
+		b.cfg.printf("=== Phase %d: %s %.2f queries/second, p50 %.3f ms, p99 %.3f ms, hit rate %.2f%% ===\n",
+			s.Phase, s.Op, s.QPS, s.P50Ms, s.P99Ms, s.HitRatePct)
+		results = append(results, result)
+		summaries = append(summaries, s)
+		if result.StoppedEarly {
+			break
+		}
+	}
+
+	// The phases ran one after another, not side by side, so the combined
+	// throughput is over their summed duration rather than summed.
+	combined := mergeSequential(b.cfg, results)
+	combined.PhaseRuns = summaries
+	return combined
+}
@@ -0,0 +1,41 @@
+package bench
+
+import (
+	"context"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// poolObserver splits each attempt of a query into the time spent inside
+// the driver before the request went out (host selection and connection
+// pick) and the time the request spent executing on the connection. It
+// forwards every observation to next, if set.
+//
+// gocql fails a request outright when a connection has no free streams,
+// so client-side queueing shows up here as wait time only while the pool
+// is picking a host; wait for a free worker under -rate is recorded
+// separately.
+type poolObserver struct {
+	stats *runStats
+	next  gocql.QueryObserver
+
+	// submitted is when the worker last handed the query to the driver;
+	// it is reset before every application-level attempt.
+	submitted time.Time
+}
+
+func (o *poolObserver) ObserveQuery(ctx context.Context, q gocql.ObservedQuery) {
+	// Driver-level retries reuse the same submission, so measure from the
+	// end of the previous attempt.
+	from := o.submitted
+	if q.Start.After(from) {
+		o.stats.recordPoolWait(q.Start.Sub(from), q.End.Sub(q.Start))
+	} else {
+		o.stats.recordPoolWait(0, q.End.Sub(q.Start))
+	}
+	o.submitted = q.End
+	if o.next != nil {
+		o.next.ObserveQuery(ctx, q)
+	}
+}
@@ -0,0 +1,68 @@
+package bench
+
+import (
+	"sync"
+	"time"
+)
+
+// Display shows the progress snapshots of one measured pass, such as the
+// CLI's -tui dashboard. Config.NewDisplay starts one per pass, and Close
+// is called when the pass ends.
+type Display interface {
+	Render(r Result)
+	Close()
+}
+
+// startProgress writes a progress line to cfg.Out every
+// cfg.ProgressInterval, or renders the snapshot on a cfg.NewDisplay, and,
+// with -webhook-url, POSTs the partial Result there as JSON, so a dashboard
+// can render the run live. A failed POST is logged and the run carries on.
+// The returned function stops reporting and waits for an in-progress POST;
+// it may be called more than once.
+func startProgress(cfg Config, snapshot func() Result) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	var display Display
+	if cfg.NewDisplay != nil {
+		var err error
+		display, err = cfg.NewDisplay()
+		if err != nil {
+			cfg.logger().Printf("Failed to start the progress display, printing progress lines instead: %v", err)
+			display = nil
+		}
+	}
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(cfg.ProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+			r := snapshot()
+			if display != nil {
+				display.Render(r)
+			} else {
+				cfg.printf("Progress: %d of %d queries, %.2f queries/second, p99 %.3f ms, %d failed\n",
+					r.CompletedQueries, r.Queries, r.QPS, r.P99Ms, r.FailedQueries)
+			}
+			if cfg.WebhookURL != "" {
+				if err := postJSON(cfg.WebhookURL, r); err != nil {
+					cfg.logger().Printf("Failed to post progress to -webhook-url: %v", err)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+			if display != nil {
+				display.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package bench
+
+import (
+	"context"
+
+	"github.com/gocql/gocql"
+)
+
+// Querier is what the worker pool issues queries through: a gocql session
+// in normal runs, an in-memory fake with -self-test.
+type Querier interface {
+	Query(stmt string, values ...interface{}) Statement
+}
+
+// Statement is the subset of *gocql.Query the workers use.
+type Statement interface {
+	Consistency(c gocql.Consistency) Statement
+	Observer(o gocql.QueryObserver) Statement
+	Trace(t gocql.Tracer) Statement
+	WithContext(ctx context.Context) Statement
+	Idempotent(value bool) Statement
+	Iter() RowIter
+}
+
+// RowIter is the subset of *gocql.Iter the workers use.
+type RowIter interface {
+	Columns() []gocql.ColumnInfo
+	Scan(dest ...interface{}) bool
+	MapScan(m map[string]interface{}) bool
+	NumRows() int
+	Close() error
+}
+
+// sessionQuerier adapts a gocql session to Querier.
+type sessionQuerier struct {
+	session *gocql.Session
+}
+
+// NewQuerier returns a Querier that issues queries on session.
+func NewQuerier(session *gocql.Session) Querier {
+	return sessionQuerier{session}
+}
+
+func (s sessionQuerier) Query(stmt string, values ...interface{}) Statement {
+	return gocqlStatement{s.session.Query(stmt, values...)}
+}
+
+type gocqlStatement struct {
+	q *gocql.Query
+}
+
+func (s gocqlStatement) Consistency(c gocql.Consistency) Statement {
+	return gocqlStatement{s.q.Consistency(c)}
+}
+
+func (s gocqlStatement) Observer(o gocql.QueryObserver) Statement {
+	return gocqlStatement{s.q.Observer(o)}
+}
+
+func (s gocqlStatement) Trace(t gocql.Tracer) Statement {
+	return gocqlStatement{s.q.Trace(t)}
+}
+
+func (s gocqlStatement) WithContext(ctx context.Context) Statement {
+	return gocqlStatement{s.q.WithContext(ctx)}
+}
+
+func (s gocqlStatement) Idempotent(value bool) Statement {
+	return gocqlStatement{s.q.Idempotent(value)}
+}
+
+func (s gocqlStatement) Iter() RowIter {
+	return s.q.Iter()
+}
@@ -0,0 +1,165 @@
+package bench
+
+import (
+	"maps"
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// repeatRuns runs the measured pass cfg.Repeat times. Unless -fixed-order is
+// set, the key order is reshuffled with the -seed rand source before every
+// iteration after the first, so later iterations do not replay the access
+// pattern that warmed the page cache. The caller gets the last iteration's
+// result with every iteration and the medians across them attached.
+func (b *benchmark) repeatRuns(opts runOptions) Result {
+	cfg := b.cfg
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	// A replay schedule is tied to the log's key order.
+	shuffle := !cfg.FixedOrder && opts.Schedule == nil
+
+	var result Result
+	var iterations []IterationResult
+	var hists []*hdrhistogram.Histogram
+	for i := 1; i <= cfg.Repeat; i++ {
+		reshuffled := shuffle && i > 1
+		if reshuffled {
+			rng.Shuffle(len(b.keys), func(x, y int) { b.keys[x], b.keys[y] = b.keys[y], b.keys[x] })
+		}
+		cfg.printf("Iteration %d/%d (reshuffled %t)...\n", i, cfg.Repeat, reshuffled)
+		result = b.run(opts)
+		if result.StoppedEarly {
+			break
+		}
+		it := IterationResult{
+			Iteration:    i,
+			Reshuffled:   reshuffled,
+			QPS:          result.QPS,
+			AvgLatencyMs: result.AvgLatencyMs,
+			P99Ms:        result.P99Ms,
+			HitRatePct:   result.HitRatePct,
+		}
+		cfg.printf("Iteration %d: %.2f queries/second, avg %.3f ms, p99 %.3f ms, hit rate %.2f%%\n",
+			i, it.QPS, it.AvgLatencyMs, it.P99Ms, it.HitRatePct)
+		iterations = append(iterations, it)
+		hists = append(hists, result.latencyHist)
+	}
+
+	result.Iterations = iterations
+	if len(iterations) > 0 {
+		result.MedianQPS = median(iterations, func(it IterationResult) float64 { return it.QPS })
+		result.MedianAvgLatencyMs = median(iterations, func(it IterationResult) float64 { return it.AvgLatencyMs })
+		result.MedianP99Ms = median(iterations, func(it IterationResult) float64 { return it.P99Ms })
+	}
+	if len(hists) > 1 {
+		result.IterationDifference = compareIterations(hists)
+	}
+	return result
+}
+
+// iterationAlpha is the family-wise significance level of the pairwise
+// iteration comparison.
+const iterationAlpha = 0.05
+
+// compareIterations runs a Mann-Whitney U test on the latencies of every
+// pair of iterations, Bonferroni-corrected for the number of pairs, and
+// reports the pair that differs most. The test works on the histograms
+// directly: every sample in a histogram bucket shares the bucket's rank.
+func compareIterations(hists []*hdrhistogram.Histogram) *SignificanceResult {
+	s := &SignificanceResult{Test: "mann-whitney-u", Alpha: iterationAlpha, MinPValue: 1}
+	for i := range hists {
+		for j := i + 1; j < len(hists); j++ {
+			if hists[i] == nil || hists[j] == nil {
+				continue
+			}
+			p, effect, ok := mannWhitneyU(hists[i], hists[j])
+			if !ok {
+				continue
+			}
+			s.Pairs++
+			if p < s.MinPValue || s.MostDifferent == [2]int{} {
+				s.MinPValue = p
+				s.MostDifferent = [2]int{i + 1, j + 1}
+				s.Effect = effect
+			}
+		}
+	}
+	if s.Pairs == 0 {
+		return nil
+	}
+	threshold := iterationAlpha / float64(s.Pairs)
+	for i := range hists {
+		for j := i + 1; j < len(hists); j++ {
+			if hists[i] == nil || hists[j] == nil {
+				continue
+			}
+			if p, _, ok := mannWhitneyU(hists[i], hists[j]); ok && p < threshold {
+				s.SignificantPairs++
+			}
+		}
+	}
+	s.Differ = s.SignificantPairs > 0
+	return s
+}
+
+// mannWhitneyU tests whether the latencies in a and b come from the same
+// distribution, using the normal approximation with tie correction, which
+// is accurate at benchmark sample sizes. It returns the two-sided p-value
+// and the effect size: the probability that a query from a is slower than
+// one from b, counting ties as half (0.5 means no difference).
+func mannWhitneyU(a, b *hdrhistogram.Histogram) (p, effect float64, ok bool) {
+	counts := make(map[int64][2]int64)
+	for i, h := range []*hdrhistogram.Histogram{a, b} {
+		for _, bar := range h.Distribution() {
+			if bar.Count > 0 {
+				c := counts[bar.From]
+				c[i] += bar.Count
+				counts[bar.From] = c
+			}
+		}
+	}
+	var n1, n2 float64
+	for _, c := range counts {
+		n1 += float64(c[0])
+		n2 += float64(c[1])
+	}
+	n := n1 + n2
+	if n1 == 0 || n2 == 0 {
+		return 0, 0, false
+	}
+
+	var below, rankSum, ties float64
+	for _, v := range slices.Sorted(maps.Keys(counts)) {
+		c := counts[v]
+		t := float64(c[0] + c[1])
+		rankSum += float64(c[0]) * (below + (t+1)/2)
+		ties += t*t*t - t
+		below += t
+	}
+	u := rankSum - n1*(n1+1)/2
+	effect = u / (n1 * n2)
+	variance := n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1)))
+	if variance <= 0 {
+		// Every sample fell in one bucket.
+		return 1, effect, true
+	}
+	z := (u - n1*n2/2) / math.Sqrt(variance)
+	return math.Erfc(math.Abs(z) / math.Sqrt2), effect, true
+}
+
+// median returns the median of field over the iterations.
+func median(iterations []IterationResult, field func(IterationResult) float64) float64 {
+	values := make([]float64, len(iterations))
+	for i, it := range iterations {
+		values[i] = field(it)
+	}
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
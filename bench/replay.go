@@ -0,0 +1,99 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// replayEntry is one recorded query in a -replay log: the key fields plus
+// the time the query was originally issued, e.g.
+//
+//	{"ts": "2025-06-01T12:00:00.125Z", "eqp_model": "m1", "strtgy_name": "s1", "job_id": "j1"}
+//
+// ts may also be a number of milliseconds since the Unix epoch.
+type replayEntry struct {
+	TS replayTime `json:"ts"`
+	QueryKey
+}
+
+// UnmarshalJSON decodes ts separately, since the QueryKey decoder promoted
+// from the embedded field would otherwise take over the whole entry.
+func (e *replayEntry) UnmarshalJSON(data []byte) error {
+	var ts struct {
+		TS replayTime `json:"ts"`
+	}
+	if err := json.Unmarshal(data, &ts); err != nil {
+		return err
+	}
+	e.TS = ts.TS
+	return e.QueryKey.UnmarshalJSON(data)
+}
+
+// replayTime accepts either an RFC 3339 string or epoch milliseconds.
+type replayTime struct {
+	time.Time
+}
+
+func (t *replayTime) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return err
+		}
+		t.Time = parsed
+		return nil
+	}
+	ms, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("invalid ts %s: expected RFC 3339 string or epoch milliseconds", data)
+	}
+	t.Time = time.UnixMilli(0).Add(time.Duration(ms * float64(time.Millisecond)))
+	return nil
+}
+
+// parseReplayLog decodes a replay log (a JSON array or NDJSON) into keys and
+// their dispatch offsets relative to the earliest entry, ordered by time.
+func parseReplayLog(data []byte) ([]QueryKey, []time.Duration, error) {
+	var entries []replayEntry
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		for {
+			var e replayEntry
+			err := dec.Decode(&e)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("entry %d: %w", len(entries)+1, err)
+			}
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, nil, nil
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].TS.Before(entries[j].TS.Time) })
+	keys := make([]QueryKey, len(entries))
+	offsets := make([]time.Duration, len(entries))
+	first := entries[0].TS.Time
+	for i, e := range entries {
+		keys[i] = e.QueryKey
+		offsets[i] = e.TS.Sub(first)
+	}
+	return keys, offsets, nil
+}
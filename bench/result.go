@@ -0,0 +1,892 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// Result holds the summary of a benchmark run.
+type Result struct {
+	// Tag labels the run with the external conditions it ran under, such
+	// as a tc/netem setting ("loss=1%"), so results can be told apart and
+	// the coordinator can group them.
+	Tag string `json:"tag,omitempty"`
+
+	Concurrency       int     `json:"concurrency"`
+	Queries           int     `json:"queries"`
+	CompletedQueries  int64   `json:"completed_queries"`
+	SuccessfulQueries int64   `json:"successful_queries"`
+	HitRatePct        float64 `json:"hit_rate_pct"`
+	FailedQueries     int64   `json:"failed_queries"`
+	DeadlineExceeded  int64   `json:"deadline_exceeded"`
+	ServerTimeouts    int64   `json:"server_timeouts"`
+	TimeoutRatePct    float64 `json:"timeout_rate_pct"`
+	AvgLatencyMs      float64 `json:"avg_latency_ms"`
+	P50Ms             float64 `json:"p50_ms"`
+	P90Ms             float64 `json:"p90_ms"`
+	P99Ms             float64 `json:"p99_ms"`
+	MaxMs             float64 `json:"max_ms"`
+	DroppedSamples    int64   `json:"dropped_samples"`
+	TotalSeconds      float64 `json:"total_seconds"`
+	QPS               float64 `json:"qps"`
+	TargetRate        float64 `json:"target_rate,omitempty"`
+	StoppedEarly      bool    `json:"stopped_early,omitempty"`
+	AvgInFlight       float64 `json:"avg_in_flight"`
+
+	// Client GC activity during the pass: cycles, total stop-the-world
+	// pause and the longest pause, to tell client-side stalls from
+	// server latency.
+	GCCycles     uint32  `json:"gc_cycles"`
+	GCPauseMs    float64 `json:"gc_pause_ms"`
+	GCMaxPauseMs float64 `json:"gc_max_pause_ms"`
+
+	// Set only with -no-prepare: the mean latency of the prepared reference
+	// pass and how much slower the unprepared pass was on average.
+	PreparedAvgLatencyMs float64 `json:"prepared_avg_latency_ms,omitempty"`
+	UnpreparedDeltaMs    float64 `json:"unprepared_delta_ms,omitempty"`
+
+	// Set only with -phase-timing.
+	Phases *PhaseTiming `json:"phases,omitempty"`
+
+	// ScanColumns is the number of columns per row discovered from the
+	// result metadata and scanned.
+	ScanColumns int `json:"scan_columns,omitempty"`
+
+	// ColumnNames lists the columns discovered by -map-scan.
+	ColumnNames []string `json:"column_names,omitempty"`
+
+	// ErrorSamples holds failing keys with their errors, recorded with
+	// -sample-errors-with-keys for reproducing in cqlsh.
+	ErrorSamples []ErrorSample `json:"error_samples,omitempty"`
+
+	// Set only with -fast-path.
+	FastPath *FastPathResult `json:"fast_path,omitempty"`
+
+	// CoordinatorDCs counts query attempts by the datacenter of their
+	// coordinator (-report-dc). CrossDCPct is the share coordinated outside
+	// -local-dc; anything above zero with DC-aware routing is a routing bug.
+	CoordinatorDCs map[string]int64 `json:"coordinator_dcs,omitempty"`
+	LocalDC        string           `json:"local_dc,omitempty"`
+
+	// Hosts breaks query attempts down by coordinator (-report-hosts).
+	// Flagged hosts fail disproportionately often next to the others.
+	Hosts []HostResult `json:"hosts,omitempty"`
+
+	// PinnedHost is the -pin-host node that coordinated every query.
+	PinnedHost string  `json:"pinned_host,omitempty"`
+	CrossDCPct float64 `json:"cross_dc_pct,omitempty"`
+
+	// CoalesceWait is the -coalesce-wait the run used, when set, so runs
+	// with different settings can be compared.
+	CoalesceWait string `json:"coalesce_wait,omitempty"`
+
+	// SlowQueries counts queries slower than -slow-query-ms.
+	SlowQueries int64 `json:"slow_queries,omitempty"`
+
+	// Set only with -measure-bytes: the estimated value bytes scanned, in
+	// total, per completed query and per second of the run.
+	BytesRead          int64   `json:"bytes_read,omitempty"`
+	BytesReadPerQuery  float64 `json:"bytes_read_per_query,omitempty"`
+	BytesReadPerSecond float64 `json:"bytes_read_per_second,omitempty"`
+
+	// Set only with -measure-bytes and writes (-write-mix, a -phases
+	// insert): the estimated value bytes bound by successful writes, in
+	// total and per second of the run.
+	BytesWritten          int64   `json:"bytes_written,omitempty"`
+	BytesWrittenPerSecond float64 `json:"bytes_written_per_second,omitempty"`
+
+	// Retries counts app-level retries (-retries); RetryBudgetExhausted
+	// counts queries that failed because -retry-budget ran out.
+	Retries              int64 `json:"retries,omitempty"`
+	RetryBudgetExhausted int64 `json:"retry_budget_exhausted,omitempty"`
+
+	// Panics counts queries that panicked; each is also a failed query.
+	Panics int64 `json:"panics,omitempty"`
+
+	// Set only with -prime: how long reading every key once took and how
+	// many of the keys matched a row.
+	PrimeSeconds    float64 `json:"prime_seconds,omitempty"`
+	PrimeHitRatePct float64 `json:"prime_hit_rate_pct,omitempty"`
+
+	// Set only with -cold-start.
+	ColdStart *ColdStartResult `json:"cold_start,omitempty"`
+
+	// Set only with -partition-only.
+	RowsPerPartition *RowsDistribution `json:"rows_per_partition,omitempty"`
+
+	// Set only with -in-list: the rows each IN query returned.
+	RowsPerQuery *RowsDistribution `json:"rows_per_query,omitempty"`
+
+	// Set only with -replay: how far behind the recorded schedule queries
+	// actually started.
+	ScheduleLagAvgMs float64 `json:"schedule_lag_avg_ms,omitempty"`
+	ScheduleLagMaxMs float64 `json:"schedule_lag_max_ms,omitempty"`
+
+	// NullKeyRows counts scanned rows whose eqp_model was NULL, a sign of
+	// partially written or corrupted rows. With -null-key-miss they count
+	// as misses rather than hits.
+	NullKeyRows int64 `json:"null_key_rows,omitempty"`
+
+	// PartitionsQueried is the number of distinct partitions read, with
+	// -max-partitions.
+	PartitionsQueried int `json:"partitions_queried,omitempty"`
+
+	// Set only with -metadata-column.
+	Metadata *MetadataResult `json:"metadata,omitempty"`
+
+	// Set only with -split-prepare: the prepared and unprepared cohorts of
+	// the same run.
+	PrepareSplit []CohortResult `json:"prepare_split,omitempty"`
+
+	// Partial marks a -progress-interval snapshot of a pass still running.
+	Partial bool `json:"partial,omitempty"`
+
+	// RunRetries is how many times -run-retries reran the benchmark because
+	// its first queries all failed.
+	RunRetries int `json:"run_retries,omitempty"`
+
+	// Set only with -write-mix.
+	WriteCheck *WriteCheckResult `json:"write_check,omitempty"`
+
+	// Set only with -pool-wait.
+	PoolWait *PoolWaitResult `json:"pool_wait,omitempty"`
+
+	// Set only with -sla-p99-ms: the highest probed rate whose p99 met the
+	// target.
+	SLAP99TargetMs float64 `json:"sla_p99_target_ms,omitempty"`
+	SLAMaxRate     float64 `json:"sla_max_rate,omitempty"`
+	// SLAMissed is set when no probe met the target; the rest of the
+	// result is then the last probe's.
+	SLAMissed bool `json:"sla_missed,omitempty"`
+
+	// Steps lists each -rate-schedule step; the rest of the result is the
+	// last step's.
+	Steps []StepResult `json:"steps,omitempty"`
+
+	// PhaseRuns lists each -phases pass; the rest of the result combines
+	// them.
+	PhaseRuns []PhaseResult `json:"phase_runs,omitempty"`
+
+	// Chunks is the number of -chunk-size chunks the run was split into.
+	Chunks int `json:"chunks,omitempty"`
+
+	// Iterations lists each -repeat iteration; the medians are taken
+	// across them.
+	Iterations         []IterationResult `json:"iterations,omitempty"`
+	MedianQPS          float64           `json:"median_qps,omitempty"`
+	MedianAvgLatencyMs float64           `json:"median_avg_latency_ms,omitempty"`
+	MedianP99Ms        float64           `json:"median_p99_ms,omitempty"`
+	// IterationDifference tests whether the iterations' latencies differ
+	// by more than run-to-run noise.
+	IterationDifference *SignificanceResult `json:"iteration_difference,omitempty"`
+
+	// ConsistencySweep lists each -sweep-consistency level; the rest of the
+	// result is the last level's. ConsistencyDifference compares the
+	// levels' latencies, numbered in sweep order.
+	ConsistencySweep      []ConsistencyLevelResult `json:"consistency_sweep,omitempty"`
+	ConsistencyDifference *SignificanceResult      `json:"consistency_difference,omitempty"`
+
+	// LatencyHistogram is the compressed HdrHistogram of all latencies,
+	// included when posting to a coordinator so percentiles can be merged.
+	LatencyHistogram string `json:"latency_histogram,omitempty"`
+
+	// timeline is the per-second -timeline-file record of the run.
+	timeline *timeline
+
+	// latencyHist is the run's latency histogram in microseconds.
+	latencyHist *hdrhistogram.Histogram
+
+	// stats are the counters the pass collected, kept so passes run one
+	// after another can be merged exactly by mergeSequential.
+	stats *runStats
+
+	// Breakdowns splits the counts by a dimension such as "target". Each
+	// dimension's groups are sorted by name.
+	Breakdowns map[string][]GroupResult `json:"breakdowns,omitempty"`
+}
+
+// PhaseTiming is the mean client-side time per query spent in each phase.
+// Bind covers building the gocql query and its values; gocql marshals the
+// values while executing, so that cost lands in Execute together with the
+// network round trip and coordinator time. Scan covers reading the rows and
+// closing the iterator.
+type PhaseTiming struct {
+	BindMs    float64 `json:"bind_ms"`
+	ExecuteMs float64 `json:"execute_ms"`
+	ScanMs    float64 `json:"scan_ms"`
+}
+
+// IterationResult summarises one -repeat iteration.
+type IterationResult struct {
+	Iteration    int     `json:"iteration"`
+	Reshuffled   bool    `json:"reshuffled"`
+	QPS          float64 `json:"qps"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	P99Ms        float64 `json:"p99_ms"`
+	HitRatePct   float64 `json:"hit_rate_pct"`
+}
+
+// ConsistencyLevelResult summarises one -sweep-consistency level. With
+// -repeat, QPS, AvgLatencyMs and P99Ms are medians across the iterations.
+type ConsistencyLevelResult struct {
+	Consistency   string  `json:"consistency"`
+	QPS           float64 `json:"qps"`
+	AvgLatencyMs  float64 `json:"avg_latency_ms"`
+	P50Ms         float64 `json:"p50_ms"`
+	P99Ms         float64 `json:"p99_ms"`
+	MaxMs         float64 `json:"max_ms"`
+	HitRatePct    float64 `json:"hit_rate_pct"`
+	FailedQueries int64   `json:"failed_queries"`
+}
+
+// SignificanceResult is a pairwise test of the -repeat iterations'
+// latency distributions. A pair differs when its p-value is below Alpha
+// divided by Pairs (Bonferroni). MostDifferent is the pair with the lowest
+// p-value and Effect the probability that a query from its first iteration
+// is slower than one from its second; with many queries even a tiny shift
+// is significant, so read Effect before calling a difference real.
+type SignificanceResult struct {
+	Test             string  `json:"test"`
+	Alpha            float64 `json:"alpha"`
+	Pairs            int     `json:"pairs"`
+	SignificantPairs int     `json:"significant_pairs"`
+	Differ           bool    `json:"differ"`
+	MostDifferent    [2]int  `json:"most_different"`
+	MinPValue        float64 `json:"min_p_value"`
+	Effect           float64 `json:"effect"`
+}
+
+// WriteCheckResult compares the -write-mix counters read back after the
+// run with the writes issued. DoubleApplied counts increments beyond one
+// per issued write, which only a retried non-idempotent write can cause,
+// and should be zero; NotApplied counts writes that failed without landing.
+// Both are net per key, so a lost and a doubled write to the same key
+// cancel out.
+type WriteCheckResult struct {
+	Table             string `json:"table"`
+	RunID             string `json:"run_id"`
+	Keys              int    `json:"keys"`
+	Writes            int64  `json:"writes"`
+	Applied           int64  `json:"applied"`
+	DoubleApplied     int64  `json:"double_applied"`
+	DoubleAppliedKeys int64  `json:"double_applied_keys"`
+	NotApplied        int64  `json:"not_applied"`
+	ReadErrors        int64  `json:"read_errors,omitempty"`
+}
+
+// HostResult summarizes the query attempts one coordinator served.
+type HostResult struct {
+	Host         string  `json:"host"`
+	DC           string  `json:"dc,omitempty"`
+	Attempts     int64   `json:"attempts"`
+	Errors       int64   `json:"errors"`
+	Timeouts     int64   `json:"timeouts"`
+	ErrorRatePct float64 `json:"error_rate_pct"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	Flagged      bool    `json:"flagged,omitempty"`
+}
+
+// StepResult summarises one -rate-schedule step.
+type StepResult struct {
+	Step          int     `json:"step"`
+	TargetRate    float64 `json:"target_rate"`
+	QPS           float64 `json:"qps"`
+	Queries       int64   `json:"queries"`
+	FailedQueries int64   `json:"failed_queries"`
+	ErrorRatePct  float64 `json:"error_rate_pct"`
+	AvgLatencyMs  float64 `json:"avg_latency_ms"`
+	P50Ms         float64 `json:"p50_ms"`
+	P90Ms         float64 `json:"p90_ms"`
+	P99Ms         float64 `json:"p99_ms"`
+	MaxMs         float64 `json:"max_ms"`
+}
+
+// PhaseResult summarises one -phases pass.
+type PhaseResult struct {
+	Phase         int     `json:"phase"`
+	Op            string  `json:"op"`
+	Keys          int     `json:"keys"`
+	Queries       int64   `json:"queries"`
+	FailedQueries int64   `json:"failed_queries"`
+	HitRatePct    float64 `json:"hit_rate_pct"`
+	TotalSeconds  float64 `json:"total_seconds"`
+	QPS           float64 `json:"qps"`
+	AvgLatencyMs  float64 `json:"avg_latency_ms"`
+	P50Ms         float64 `json:"p50_ms"`
+	P99Ms         float64 `json:"p99_ms"`
+	MaxMs         float64 `json:"max_ms"`
+}
+
+// MetadataResult describes the write age and remaining TTL of the
+// -metadata-column cells read, one per key that had the cell set.
+type MetadataResult struct {
+	Column      string  `json:"column"`
+	Cells       int64   `json:"cells"`
+	AgeMinHours float64 `json:"age_min_hours"`
+	AgeAvgHours float64 `json:"age_avg_hours"`
+	AgeMaxHours float64 `json:"age_max_hours"`
+	WithTTLPct  float64 `json:"with_ttl_pct"`
+	// TTLMinHours and TTLAvgHours cover the cells that have a TTL.
+	TTLMinHours   float64 `json:"ttl_min_hours,omitempty"`
+	TTLAvgHours   float64 `json:"ttl_avg_hours,omitempty"`
+	ExpiryWindowH float64 `json:"expiry_window_hours"`
+	NearExpiryPct float64 `json:"near_expiry_pct"`
+}
+
+// CohortResult summarizes the queries of one -split-prepare cohort.
+type CohortResult struct {
+	Name          string  `json:"name"`
+	Queries       int64   `json:"queries"`
+	FailedQueries int64   `json:"failed_queries"`
+	AvgLatencyMs  float64 `json:"avg_latency_ms"`
+	P50Ms         float64 `json:"p50_ms"`
+	P90Ms         float64 `json:"p90_ms"`
+	P99Ms         float64 `json:"p99_ms"`
+	MaxMs         float64 `json:"max_ms"`
+}
+
+// PoolWaitResult splits query attempts into time spent waiting in the
+// client before execution began and time spent executing. A wait that is
+// large next to the execute time points at the client, not the cluster.
+type PoolWaitResult struct {
+	Attempts  int64   `json:"attempts"`
+	WaitAvgMs float64 `json:"wait_avg_ms"`
+	WaitMaxMs float64 `json:"wait_max_ms"`
+	ExecAvgMs float64 `json:"exec_avg_ms"`
+	ExecMaxMs float64 `json:"exec_max_ms"`
+
+	// Set only with -rate: how long queries waited past their due time for
+	// a free worker.
+	SlotWaitAvgMs float64 `json:"slot_wait_avg_ms,omitempty"`
+	SlotWaitMaxMs float64 `json:"slot_wait_max_ms,omitempty"`
+}
+
+// FastPathResult describes the LOCAL_ONE-first read strategy: how often
+// LOCAL_ONE alone answered, how often QUORUM had to be asked, and the mean
+// latency each fallback added.
+type FastPathResult struct {
+	SuccessPct    float64 `json:"success_pct"`
+	Fallbacks     int64   `json:"fallbacks"`
+	FallbackAvgMs float64 `json:"fallback_avg_ms"`
+}
+
+// ErrorSample is one failed query: the target it ran against, the key it
+// bound and the error it returned.
+type ErrorSample struct {
+	Target string   `json:"target"`
+	Key    QueryKey `json:"key"`
+	Error  string   `json:"error"`
+}
+
+// ColdStartResult compares queries that ran on a freshly created session
+// with the steady state that followed.
+type ColdStartResult struct {
+	FirstQueryMs     float64 `json:"first_query_ms"`
+	WorkerFirstAvgMs float64 `json:"worker_first_avg_ms"`
+	SteadyStateAvgMs float64 `json:"steady_state_avg_ms"`
+}
+
+// RowsDistribution summarizes how many rows each query returned.
+type RowsDistribution struct {
+	Min  int64   `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  int64   `json:"p50"`
+	P99  int64   `json:"p99"`
+	Max  int64   `json:"max"`
+
+	// Set only with -limit: the bound limit and the share of reads that
+	// returned that many rows.
+	Limit          int     `json:"limit,omitempty"`
+	LimitFilledPct float64 `json:"limit_filled_pct,omitempty"`
+}
+
+// GroupResult summarizes the queries that fell into one group of a
+// breakdown dimension.
+type GroupResult struct {
+	Name              string  `json:"name"`
+	Queries           int64   `json:"queries"`
+	SharePct          float64 `json:"share_pct"`
+	SuccessfulQueries int64   `json:"successful_queries"`
+	HitRatePct        float64 `json:"hit_rate_pct"`
+	FailedQueries     int64   `json:"failed_queries"`
+	AvgLatencyMs      float64 `json:"avg_latency_ms"`
+}
+
+// sortedKeys returns the keys of m in sorted order. Every map in the
+// summary is printed through it, so text and markdown output is stable
+// between runs and diffs cleanly; encoding/json already sorts map keys.
+func sortedKeys[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
+
+// Print writes the summary to w in the given output format: text, json or
+// markdown.
+func (r Result) Print(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		return r.printJSON(w)
+	case "markdown":
+		r.printMarkdown(w)
+	default:
+		r.printText(w)
+	}
+	return nil
+}
+
+// printText writes the human-readable summary.
+func (r Result) printText(w io.Writer) {
+	if r.Tag != "" {
+		fmt.Fprintf(w, "Tag: %s\n", r.Tag)
+	}
+	fmt.Fprintf(w, "Total completed queries: %d of %d\n", r.CompletedQueries, r.Queries)
+	fmt.Fprintf(w, "Total successful queries: %d\n", r.SuccessfulQueries)
+	fmt.Fprintf(w, "Hit rate: %.2f%%\n", r.HitRatePct)
+	fmt.Fprintf(w, "Total failed queries: %d\n", r.FailedQueries)
+	fmt.Fprintf(w, "Client deadline exceeded: %d (%.2f%% of queries)\n", r.DeadlineExceeded, r.TimeoutRatePct)
+	fmt.Fprintf(w, "Server read/write timeouts: %d\n", r.ServerTimeouts)
+	fmt.Fprintf(w, "Average latency: %.3f ms\n", r.AvgLatencyMs)
+	fmt.Fprintf(w, "Latency percentiles: p50 %.3f ms, p90 %.3f ms, p99 %.3f ms, max %.3f ms\n", r.P50Ms, r.P90Ms, r.P99Ms, r.MaxMs)
+	if r.DroppedSamples > 0 {
+		fmt.Fprintf(w, "Dropped latency samples: %d (raise -result-buffer)\n", r.DroppedSamples)
+	}
+	if p := r.Phases; p != nil {
+		total := p.BindMs + p.ExecuteMs + p.ScanMs
+		share := func(ms float64) float64 {
+			if total == 0 {
+				return 0
+			}
+			return ms / total * 100
+		}
+		fmt.Fprintf(w, "Phase split: bind %.3f ms (%.1f%%), execute %.3f ms (%.1f%%), scan %.3f ms (%.1f%%)\n",
+			p.BindMs, share(p.BindMs), p.ExecuteMs, share(p.ExecuteMs), p.ScanMs, share(p.ScanMs))
+	}
+	if d := r.RowsPerQuery; d != nil {
+		fmt.Fprintf(w, "Rows per IN query: min %d, mean %.1f, p50 %d, p99 %d, max %d\n", d.Min, d.Mean, d.P50, d.P99, d.Max)
+	}
+	if d := r.RowsPerPartition; d != nil {
+		fmt.Fprintf(w, "Rows per partition: min %d, mean %.1f, p50 %d, p99 %d, max %d\n", d.Min, d.Mean, d.P50, d.P99, d.Max)
+		if d.Limit > 0 {
+			fmt.Fprintf(w, "Rows vs limit: mean %.1f of %d, %.1f%% of reads filled the limit\n", d.Mean, d.Limit, d.LimitFilledPct)
+		}
+	}
+	if len(r.ErrorSamples) > 0 {
+		fmt.Fprintf(w, "Sample failures (%d of %d):\n", len(r.ErrorSamples), r.FailedQueries)
+		for _, e := range r.ErrorSamples {
+			fmt.Fprintf(w, "  %s eqp_model=%q job_id=%q strtgy_name=%q", e.Target, e.Key.EqpModel, e.Key.JobID, e.Key.StrategyName)
+			if e.Key.Bucket != "" {
+				fmt.Fprintf(w, " bucket=%q", e.Key.Bucket)
+			}
+			fmt.Fprintf(w, ": %s\n", e.Error)
+		}
+	}
+	if r.ScanColumns > 0 {
+		fmt.Fprintf(w, "Columns scanned per row: %d\n", r.ScanColumns)
+	}
+	if len(r.ColumnNames) > 0 {
+		fmt.Fprintf(w, "Columns discovered: %s\n", strings.Join(r.ColumnNames, ", "))
+	}
+	if f := r.FastPath; f != nil {
+		fmt.Fprintf(w, "LOCAL_ONE fast path: %.2f%% served, %d QUORUM fallbacks adding %.3f ms on average\n", f.SuccessPct, f.Fallbacks, f.FallbackAvgMs)
+	}
+	if r.CoalesceWait != "" {
+		fmt.Fprintf(w, "Write coalescing wait: %s\n", r.CoalesceWait)
+	}
+	if r.PinnedHost != "" {
+		fmt.Fprintf(w, "Pinned coordinator: %s (load balancing disabled)\n", r.PinnedHost)
+	}
+	if len(r.Hosts) > 0 {
+		fmt.Fprintln(w, "Per-host attempts:")
+		for _, h := range r.Hosts {
+			mark := ""
+			if h.Flagged {
+				mark = "  <-- high error rate"
+			}
+			fmt.Fprintf(w, "  %-20s %-10s attempts %d, errors %d (%.2f%%), timeouts %d, avg latency %.3f ms%s\n",
+				h.Host, h.DC, h.Attempts, h.Errors, h.ErrorRatePct, h.Timeouts, h.AvgLatencyMs, mark)
+		}
+	}
+	if len(r.CoordinatorDCs) > 0 {
+		fmt.Fprintf(w, "Coordinator datacenters:")
+		for _, dc := range sortedKeys(r.CoordinatorDCs) {
+			fmt.Fprintf(w, " %s %d", dc, r.CoordinatorDCs[dc])
+		}
+		if r.LocalDC != "" {
+			fmt.Fprintf(w, " (cross-DC from %s: %.2f%%)", r.LocalDC, r.CrossDCPct)
+		}
+		fmt.Fprintln(w)
+	}
+	if r.SlowQueries > 0 {
+		fmt.Fprintf(w, "Slow queries: %d (%.2f%% of queries)\n", r.SlowQueries, percent(r.SlowQueries, r.CompletedQueries))
+	}
+	if r.BytesRead > 0 {
+		fmt.Fprintf(w, "Bytes read (estimated): %d total, %.1f per query, %.2f MB/s\n", r.BytesRead, r.BytesReadPerQuery, r.BytesReadPerSecond/1e6)
+	}
+	if r.BytesWritten > 0 {
+		fmt.Fprintf(w, "Bytes written (estimated): %d total, %.2f MB/s\n", r.BytesWritten, r.BytesWrittenPerSecond/1e6)
+	}
+	if r.Panics > 0 {
+		fmt.Fprintf(w, "Recovered query panics: %d (counted as failed queries)\n", r.Panics)
+	}
+	if r.Retries > 0 || r.RetryBudgetExhausted > 0 {
+		fmt.Fprintf(w, "App-level retries: %d (retry budget exhausted %d)\n", r.Retries, r.RetryBudgetExhausted)
+	}
+	if r.PrimeSeconds > 0 {
+		fmt.Fprintf(w, "Priming pass: %.2f seconds, hit rate %.2f%%\n", r.PrimeSeconds, r.PrimeHitRatePct)
+	}
+	if c := r.ColdStart; c != nil {
+		fmt.Fprintf(w, "Cold start: first query %.3f ms, first query per worker avg %.3f ms, steady state avg %.3f ms\n",
+			c.FirstQueryMs, c.WorkerFirstAvgMs, c.SteadyStateAvgMs)
+	}
+	if r.NullKeyRows > 0 {
+		fmt.Fprintf(w, "Rows with NULL eqp_model: %d\n", r.NullKeyRows)
+	}
+	if r.PartitionsQueried > 0 {
+		fmt.Fprintf(w, "Distinct partitions queried: %d\n", r.PartitionsQueried)
+	}
+	if m := r.Metadata; m != nil {
+		fmt.Fprintf(w, "Write age of %s over %d cells: min %.1f h, avg %.1f h, max %.1f h\n", m.Column, m.Cells, m.AgeMinHours, m.AgeAvgHours, m.AgeMaxHours)
+		fmt.Fprintf(w, "TTL: %.2f%% of cells have one", m.WithTTLPct)
+		if m.WithTTLPct > 0 {
+			fmt.Fprintf(w, " (min %.1f h, avg %.1f h remaining)", m.TTLMinHours, m.TTLAvgHours)
+		}
+		fmt.Fprintf(w, ", %.2f%% expire within %.1f h\n", m.NearExpiryPct, m.ExpiryWindowH)
+	}
+	if len(r.PrepareSplit) > 0 {
+		fmt.Fprintln(w, "Prepared vs unprepared:")
+		for _, c := range r.PrepareSplit {
+			fmt.Fprintf(w, "  %-10s queries %d, failed %d, avg %.3f ms, p50 %.3f ms, p90 %.3f ms, p99 %.3f ms, max %.3f ms\n",
+				c.Name, c.Queries, c.FailedQueries, c.AvgLatencyMs, c.P50Ms, c.P90Ms, c.P99Ms, c.MaxMs)
+		}
+	}
+	if c := r.WriteCheck; c != nil {
+		fmt.Fprintf(w, "Write check on %s (run %s): %d writes to %d keys, %d applied; double-applied %d (%d keys), not applied %d",
+			c.Table, c.RunID, c.Writes, c.Keys, c.Applied, c.DoubleApplied, c.DoubleAppliedKeys, c.NotApplied)
+		if c.ReadErrors > 0 {
+			fmt.Fprintf(w, ", %d unreadable", c.ReadErrors)
+		}
+		fmt.Fprintln(w)
+	}
+	if p := r.PoolWait; p != nil {
+		fmt.Fprintf(w, "Client wait before execution: avg %.3f ms, max %.3f ms; executing: avg %.3f ms, max %.3f ms (%d attempts)\n",
+			p.WaitAvgMs, p.WaitMaxMs, p.ExecAvgMs, p.ExecMaxMs, p.Attempts)
+		if p.SlotWaitAvgMs > 0 || p.SlotWaitMaxMs > 0 {
+			fmt.Fprintf(w, "Wait for a free worker: avg %.3f ms, max %.3f ms\n", p.SlotWaitAvgMs, p.SlotWaitMaxMs)
+		}
+	}
+	if r.ScheduleLagAvgMs > 0 || r.ScheduleLagMaxMs > 0 {
+		fmt.Fprintf(w, "Replay scheduling lag: avg %.3f ms, max %.3f ms\n", r.ScheduleLagAvgMs, r.ScheduleLagMaxMs)
+	}
+	if r.SLAMissed {
+		fmt.Fprintf(w, "SLA missed: no probe met p99 <= %.3f ms; the figures above are the last probe's, at %.2f queries/second\n", r.SLAP99TargetMs, r.TargetRate)
+	} else if r.SLAP99TargetMs > 0 {
+		fmt.Fprintf(w, "Highest rate meeting p99 <= %.3f ms: %.2f queries/second\n", r.SLAP99TargetMs, r.SLAMaxRate)
+	}
+	if r.PreparedAvgLatencyMs != 0 {
+		fmt.Fprintf(w, "Prepared reference latency: %.3f ms (unprepared delta %+.3f ms)\n", r.PreparedAvgLatencyMs, r.UnpreparedDeltaMs)
+	}
+	if r.Chunks > 0 {
+		fmt.Fprintf(w, "Key chunks: %d\n", r.Chunks)
+	}
+	if len(r.Steps) > 0 {
+		fmt.Fprintf(w, "Rate steps (last one detailed above):\n")
+		for _, s := range r.Steps {
+			fmt.Fprintf(w, "  #%-3d target %.2f/s: %.2f queries/second, avg %.3f ms, p50 %.3f ms, p90 %.3f ms, p99 %.3f ms, max %.3f ms, errors %.2f%%\n",
+				s.Step, s.TargetRate, s.QPS, s.AvgLatencyMs, s.P50Ms, s.P90Ms, s.P99Ms, s.MaxMs, s.ErrorRatePct)
+		}
+	}
+	if len(r.PhaseRuns) > 0 {
+		fmt.Fprintf(w, "Phases (combined above):\n")
+		for _, p := range r.PhaseRuns {
+			fmt.Fprintf(w, "  #%-3d %-5s %d queries over %d keys in %.2f s: %.2f queries/second, avg %.3f ms, p50 %.3f ms, p99 %.3f ms, max %.3f ms, hit rate %.2f%%, failed %d\n",
+				p.Phase, p.Op, p.Queries, p.Keys, p.TotalSeconds, p.QPS, p.AvgLatencyMs, p.P50Ms, p.P99Ms, p.MaxMs, p.HitRatePct, p.FailedQueries)
+		}
+	}
+	if len(r.Iterations) > 0 {
+		fmt.Fprintf(w, "Iterations (last one detailed above):\n")
+		for _, it := range r.Iterations {
+			fmt.Fprintf(w, "  #%-3d reshuffled %-5t %.2f queries/second, avg %.3f ms, p99 %.3f ms\n",
+				it.Iteration, it.Reshuffled, it.QPS, it.AvgLatencyMs, it.P99Ms)
+		}
+		fmt.Fprintf(w, "Median of %d iterations: %.2f queries/second, avg %.3f ms, p99 %.3f ms\n",
+			len(r.Iterations), r.MedianQPS, r.MedianAvgLatencyMs, r.MedianP99Ms)
+	}
+	if len(r.ConsistencySweep) > 0 {
+		// Bars scale each level's p99 to the slowest level's.
+		var peak float64
+		for _, c := range r.ConsistencySweep {
+			peak = max(peak, c.P99Ms)
+		}
+		fmt.Fprintf(w, "Consistency sweep (last level detailed above):\n")
+		for _, c := range r.ConsistencySweep {
+			frac := 0.0
+			if peak > 0 {
+				frac = c.P99Ms / peak
+			}
+			fmt.Fprintf(w, "  %-12s %10.2f queries/second, p50 %8.3f ms, p99 %8.3f ms %s\n",
+				c.Consistency, c.QPS, c.P50Ms, c.P99Ms, ProgressBar(frac, 30))
+		}
+		if d := r.ConsistencyDifference; d != nil {
+			first, second := r.ConsistencySweep[d.MostDifferent[0]-1].Consistency, r.ConsistencySweep[d.MostDifferent[1]-1].Consistency
+			fmt.Fprintf(w, "  %d of %d level pairs differ significantly (Mann-Whitney U, alpha %.2f Bonferroni-corrected); most different: %s vs %s, P(%s slower) %.3f\n",
+				d.SignificantPairs, d.Pairs, d.Alpha, first, second, first, d.Effect)
+		}
+	}
+	if d := r.IterationDifference; d != nil {
+		verdict := "no significant difference, consistent with run-to-run noise"
+		if d.Differ {
+			verdict = fmt.Sprintf("%d of %d pairs differ significantly", d.SignificantPairs, d.Pairs)
+		}
+		fmt.Fprintf(w, "Iteration latencies (Mann-Whitney U, alpha %.2f Bonferroni-corrected): %s\n", d.Alpha, verdict)
+		fmt.Fprintf(w, "  most different: #%d vs #%d, p %.3g, P(#%d slower) %.3f\n",
+			d.MostDifferent[0], d.MostDifferent[1], d.MinPValue, d.MostDifferent[0], d.Effect)
+	}
+	if r.RunRetries > 0 {
+		fmt.Fprintf(w, "Whole-run retries: %d (first queries all failed)\n", r.RunRetries)
+	}
+	fmt.Fprintf(w, "Total time taken: %.2f seconds\n", r.TotalSeconds)
+	if r.TargetRate > 0 {
+		fmt.Fprintf(w, "Throughput: %.2f queries/second (target %.2f)\n", r.QPS, r.TargetRate)
+	} else {
+		fmt.Fprintf(w, "Throughput: %.2f queries/second\n", r.QPS)
+	}
+	fmt.Fprintf(w, "Average in-flight queries: %.2f (requested concurrency %d)\n", r.AvgInFlight, r.Concurrency)
+	fmt.Fprintf(w, "Client GC: %d cycles, %.3f ms total pause, longest %.3f ms\n", r.GCCycles, r.GCPauseMs, r.GCMaxPauseMs)
+
+	for _, dim := range sortedKeys(r.Breakdowns) {
+		fmt.Fprintf(w, "Per-%s breakdown:\n", dim)
+		for _, g := range r.Breakdowns[dim] {
+			fmt.Fprintf(w, "  %-30s queries %d (%.2f%%), hit rate %.2f%%, failed %d, avg latency %.3f ms\n",
+				g.Name, g.Queries, g.SharePct, g.HitRatePct, g.FailedQueries, g.AvgLatencyMs)
+		}
+	}
+}
+
+// printJSON writes the summary as a single JSON document.
+func (r Result) printJSON(w io.Writer) error {
+	out, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
+
+// printMarkdown writes the summary as a Markdown table with the run's
+// parameters in the left column pair and its metrics in the right one, for
+// pasting into pull requests and tickets.
+func (r Result) printMarkdown(w io.Writer) {
+	params := [][2]string{
+		{"Concurrency", fmt.Sprint(r.Concurrency)},
+		{"Queries", fmt.Sprint(r.Queries)},
+	}
+	if r.Tag != "" {
+		params = append([][2]string{{"Tag", r.Tag}}, params...)
+	}
+	if r.TargetRate > 0 {
+		params = append(params, [2]string{"Target rate", fmt.Sprintf("%.2f/s", r.TargetRate)})
+	}
+	if r.SLAP99TargetMs > 0 {
+		params = append(params, [2]string{"SLA p99 target", fmt.Sprintf("%.3f ms", r.SLAP99TargetMs)})
+	}
+	if len(r.Steps) > 0 {
+		params = append(params, [2]string{"Rate steps", fmt.Sprint(len(r.Steps))})
+	}
+	if len(r.PhaseRuns) > 0 {
+		params = append(params, [2]string{"Phases", fmt.Sprint(len(r.PhaseRuns))})
+	}
+	if len(r.ConsistencySweep) > 0 {
+		params = append(params, [2]string{"Consistency levels", fmt.Sprint(len(r.ConsistencySweep))})
+	}
+	if len(r.Iterations) > 0 {
+		params = append(params, [2]string{"Iterations", fmt.Sprint(len(r.Iterations))})
+	}
+	if r.CoalesceWait != "" {
+		params = append(params, [2]string{"Coalesce wait", r.CoalesceWait})
+	}
+	if r.PinnedHost != "" {
+		params = append(params, [2]string{"Pinned host", r.PinnedHost})
+	}
+	if r.RunRetries > 0 {
+		params = append(params, [2]string{"Run retries", fmt.Sprint(r.RunRetries)})
+	}
+	if r.StoppedEarly {
+		params = append(params, [2]string{"Stopped early", "yes"})
+	}
+
+	metrics := [][2]string{
+		{"Completed", fmt.Sprintf("%d of %d", r.CompletedQueries, r.Queries)},
+		{"Hit rate", fmt.Sprintf("%.2f%%", r.HitRatePct)},
+		{"Failed", fmt.Sprint(r.FailedQueries)},
+		{"Client timeouts", fmt.Sprintf("%d (%.2f%%)", r.DeadlineExceeded, r.TimeoutRatePct)},
+		{"Server timeouts", fmt.Sprint(r.ServerTimeouts)},
+		{"Throughput", fmt.Sprintf("%.2f queries/s", r.QPS)},
+		{"Avg latency", fmt.Sprintf("%.3f ms", r.AvgLatencyMs)},
+		{"p50", fmt.Sprintf("%.3f ms", r.P50Ms)},
+		{"p90", fmt.Sprintf("%.3f ms", r.P90Ms)},
+		{"p99", fmt.Sprintf("%.3f ms", r.P99Ms)},
+		{"Max", fmt.Sprintf("%.3f ms", r.MaxMs)},
+		{"Avg in-flight", fmt.Sprintf("%.2f", r.AvgInFlight)},
+		{"Client GC pause", fmt.Sprintf("%.3f ms over %d cycles (longest %.3f ms)", r.GCPauseMs, r.GCCycles, r.GCMaxPauseMs)},
+		{"Total time", fmt.Sprintf("%.2f s", r.TotalSeconds)},
+	}
+	if r.PartitionsQueried > 0 {
+		metrics = append(metrics, [2]string{"Partitions queried", fmt.Sprint(r.PartitionsQueried)})
+	}
+	if r.Panics > 0 {
+		metrics = append(metrics, [2]string{"Recovered panics", fmt.Sprint(r.Panics)})
+	}
+	if c := r.WriteCheck; c != nil {
+		metrics = append(metrics, [2]string{"Double-applied writes", fmt.Sprintf("%d of %d", c.DoubleApplied, c.Writes)})
+	}
+	if r.SLAMaxRate > 0 {
+		metrics = append(metrics, [2]string{"SLA max rate", fmt.Sprintf("%.2f/s", r.SLAMaxRate)})
+	}
+	if r.SLAMissed {
+		metrics = append(metrics, [2]string{"SLA max rate", "missed at every probed rate"})
+	}
+	if len(r.Iterations) > 0 {
+		metrics = append(metrics,
+			[2]string{"Median throughput", fmt.Sprintf("%.2f queries/s", r.MedianQPS)},
+			[2]string{"Median p99", fmt.Sprintf("%.3f ms", r.MedianP99Ms)})
+	}
+	if d := r.IterationDifference; d != nil {
+		metrics = append(metrics, [2]string{"Iterations differ", fmt.Sprintf("%d of %d pairs (min p %.3g)", d.SignificantPairs, d.Pairs, d.MinPValue)})
+	}
+
+	fmt.Fprintln(w, "| Parameter | Value | Metric | Value |")
+	fmt.Fprintln(w, "|---|---|---|---|")
+	for i := 0; i < len(params) || i < len(metrics); i++ {
+		var p, m [2]string
+		if i < len(params) {
+			p = params[i]
+		}
+		if i < len(metrics) {
+			m = metrics[i]
+		}
+		fmt.Fprintf(w, "| %s | %s | %s | %s |\n", p[0], p[1], m[0], m[1])
+	}
+
+	if len(r.PrepareSplit) > 0 {
+		fmt.Fprintln(w, "\n| Cohort | Queries | Failed | Avg latency | p50 | p90 | p99 | Max |")
+		fmt.Fprintln(w, "|---|---:|---:|---:|---:|---:|---:|---:|")
+		for _, c := range r.PrepareSplit {
+			fmt.Fprintf(w, "| %s | %d | %d | %.3f ms | %.3f ms | %.3f ms | %.3f ms | %.3f ms |\n",
+				c.Name, c.Queries, c.FailedQueries, c.AvgLatencyMs, c.P50Ms, c.P90Ms, c.P99Ms, c.MaxMs)
+		}
+	}
+
+	if len(r.Hosts) > 0 {
+		fmt.Fprintln(w, "\n| Host | DC | Attempts | Errors | Error rate | Timeouts | Avg latency | Flagged |")
+		fmt.Fprintln(w, "|---|---|---:|---:|---:|---:|---:|---|")
+		for _, h := range r.Hosts {
+			flagged := ""
+			if h.Flagged {
+				flagged = "yes"
+			}
+			fmt.Fprintf(w, "| %s | %s | %d | %d | %.2f%% | %d | %.3f ms | %s |\n",
+				h.Host, h.DC, h.Attempts, h.Errors, h.ErrorRatePct, h.Timeouts, h.AvgLatencyMs, flagged)
+		}
+	}
+
+	if len(r.Steps) > 0 {
+		fmt.Fprintln(w, "\n| Step | Target rate | Throughput | Avg latency | p50 | p90 | p99 | Max | Errors |")
+		fmt.Fprintln(w, "|---:|---:|---:|---:|---:|---:|---:|---:|---:|")
+		for _, s := range r.Steps {
+			fmt.Fprintf(w, "| %d | %.2f/s | %.2f/s | %.3f ms | %.3f ms | %.3f ms | %.3f ms | %.3f ms | %.2f%% |\n",
+				s.Step, s.TargetRate, s.QPS, s.AvgLatencyMs, s.P50Ms, s.P90Ms, s.P99Ms, s.MaxMs, s.ErrorRatePct)
+		}
+	}
+
+	if len(r.PhaseRuns) > 0 {
+		fmt.Fprintln(w, "\n| Phase | Op | Keys | Queries | Duration | Throughput | Avg latency | p50 | p99 | Max | Hit rate | Failed |")
+		fmt.Fprintln(w, "|---:|---|---:|---:|---:|---:|---:|---:|---:|---:|---:|---:|")
+		for _, p := range r.PhaseRuns {
+			fmt.Fprintf(w, "| %d | %s | %d | %d | %.2f s | %.2f/s | %.3f ms | %.3f ms | %.3f ms | %.3f ms | %.2f%% | %d |\n",
+				p.Phase, p.Op, p.Keys, p.Queries, p.TotalSeconds, p.QPS, p.AvgLatencyMs, p.P50Ms, p.P99Ms, p.MaxMs, p.HitRatePct, p.FailedQueries)
+		}
+	}
+
+	if len(r.ConsistencySweep) > 0 {
+		fmt.Fprintln(w, "\n| Consistency | Throughput | Avg latency | p50 | p99 | Max | Hit rate | Failed |")
+		fmt.Fprintln(w, "|---|---:|---:|---:|---:|---:|---:|---:|")
+		for _, c := range r.ConsistencySweep {
+			fmt.Fprintf(w, "| %s | %.2f/s | %.3f ms | %.3f ms | %.3f ms | %.3f ms | %.2f%% | %d |\n",
+				c.Consistency, c.QPS, c.AvgLatencyMs, c.P50Ms, c.P99Ms, c.MaxMs, c.HitRatePct, c.FailedQueries)
+		}
+	}
+
+	for _, dim := range sortedKeys(r.Breakdowns) {
+		fmt.Fprintf(w, "\n| %s | Queries | Share | Hit rate | Failed | Avg latency |\n", dim)
+		fmt.Fprintln(w, "|---|---:|---:|---:|---:|---:|")
+		for _, g := range r.Breakdowns[dim] {
+			fmt.Fprintf(w, "| %s | %d | %.2f%% | %.2f%% | %d | %.3f ms |\n",
+				strings.ReplaceAll(g.Name, "|", "\\|"), g.Queries, g.SharePct, g.HitRatePct, g.FailedQueries, g.AvgLatencyMs)
+		}
+	}
+}
+
+// inFlightSampler periodically samples an in-flight gauge so the run can
+// report the concurrency it actually sustained. An average well below the
+// requested concurrency means workers were idle (think time, client-side
+// limits); an average close to it means the cluster was the bottleneck.
+// Samples also go to the -timeline-file timeline when there is one.
+type inFlightSampler struct {
+	gauge   *atomic.Int64
+	sum     int64
+	samples int64
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func startInFlightSampler(gauge *atomic.Int64, interval time.Duration, tl *timeline) *inFlightSampler {
+	s := &inFlightSampler{
+		gauge: gauge,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n := s.gauge.Load()
+				s.sum += n
+				s.samples++
+				if tl != nil {
+					tl.sampleInFlight(n)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	return s
+}
+
+// Average stops the sampler and returns the mean of the collected samples.
+func (s *inFlightSampler) Average() float64 {
+	close(s.stop)
+	<-s.done
+	if s.samples == 0 {
+		return 0
+	}
+	return float64(s.sum) / float64(s.samples)
+}
+
+// ProgressBar renders frac (0 to 1) as a bar of width cells, as the text
+// summary draws the consistency sweep.
+func ProgressBar(frac float64, width int) string {
+	filled := int(frac * float64(width))
+	filled = max(0, min(filled, width))
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", width-filled) + "]"
+}
@@ -0,0 +1,311 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// RunBenchmark runs the benchmark cfg describes on session and returns its
+// summary without printing it, so the run can be driven from other Go code
+// and rendered with Result.Print. It loads the keys file, runs the warm-up
+// and priming passes, and then the measured pass in the configured mode.
+//
+// A Result with StoppedEarly set is partial because ctx was done. When
+// -fail-fast stopped the run, the partial Result is returned together with
+// an error wrapping ErrFailFast; when the -run-retries probe did, with one
+// wrapping ErrNotReady; when the table or a column is missing, with one
+// wrapping ErrSchema; when a panic outside a query did, with one wrapping
+// ErrPanic. Progress lines go to cfg.Out and warnings to cfg.Log.
+func RunBenchmark(ctx context.Context, session *gocql.Session, cfg Config) (Result, error) {
+	return Run(ctx, NewQuerier(session), cfg)
+}
+
+// Run is RunBenchmark against any Querier, such as a NewFakeQuerier.
+// Tracing and -cold-start need one from NewQuerier.
+func Run(ctx context.Context, querier Querier, cfg Config) (Result, error) {
+	var keys []QueryKey
+	var schedule []time.Duration
+	var err error
+	if len(cfg.Phases) > 0 && cfg.KeysFilePath == "" {
+		keys, err = phaseKeys(cfg)
+		if err != nil {
+			return Result{}, err
+		}
+	} else if cfg.ChunkSize == 0 && !cfg.StreamKeys {
+		keys, schedule, err = loadKeys(&cfg)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	ctx, abort := context.WithCancelCause(ctx)
+	defer abort(nil)
+
+	// Server-side tracing makes the coordinator record every step of the
+	// query, and the trace writer issues extra reads against system_traces,
+	// so sampled queries are noticeably slower than untraced ones.
+	var tracer gocql.Tracer
+	if cfg.TraceSample > 0 {
+		sq, ok := querier.(sessionQuerier)
+		if !ok {
+			return Result{}, errors.New("CQL tracing needs a Cassandra session")
+		}
+		if cfg.TraceOut == nil {
+			return Result{}, errors.New("CQL tracing needs a TraceOut")
+		}
+		cfg.logger().Printf("WARNING: CQL tracing enabled for %.2f%% of queries; traced queries add server load and skew latency.", cfg.TraceSample*100)
+		tracer = gocql.NewTraceWriter(sq.session, cfg.TraceOut)
+	}
+
+	if cfg.PrintSampleQuery {
+		printSampleQuery(cfg, keys[0])
+	}
+	cfg.printf("Executing %d concurrent queries with a concurrency level of %d...\n", cfg.NumQueries, cfg.Concurrency)
+
+	bench := &benchmark{ctx: ctx, cfg: cfg, querier: querier, keys: keys, tracer: tracer, targets: cfg.Targets, abort: abort}
+	if cfg.RunRetries > 0 {
+		bench.probe = &readinessProbe{want: cfg.RunRetryProbe}
+	}
+	if cfg.Timeline {
+		bench.timeline = &timeline{}
+	}
+	if cfg.WriteMix > 0 {
+		bench.writes = newWriteLedger(cfg.CounterTable)
+		cfg.printf("Writing %.2f%% of queries as counter increments to %s (run %s)\n", cfg.WriteMix*100, cfg.CounterTable, bench.writes.runID)
+	}
+
+	if cfg.ChunkSize > 0 || cfg.StreamKeys {
+		run := bench.runChunked
+		if cfg.StreamKeys {
+			run = bench.runStreamed
+		}
+		result, err := run()
+		if err != nil {
+			return result, err
+		}
+		if bench.writes != nil {
+			result.WriteCheck = bench.writes.verify(querier, cfg)
+		}
+		result.timeline = bench.timeline
+		return result, abortCause(ctx)
+	}
+
+	if cfg.Warmup > 0 {
+		cfg.printf("Warming up with %d queries (results discarded)...\n", cfg.Warmup)
+		bench.run(runOptions{Queries: cfg.Warmup, Discard: true})
+	}
+	var prime Result
+	if cfg.Prime {
+		// Read every key exactly once so the measured pass starts with a
+		// warm row and page cache across the whole key set.
+		cfg.printf("Priming %d keys (results discarded)...\n", len(keys))
+		prime = bench.run(runOptions{Queries: len(keys), Discard: true})
+		cfg.printf("Primed %d keys in %.2f seconds, hit rate %.2f%%\n", prime.CompletedQueries, prime.TotalSeconds, prime.HitRatePct)
+	}
+	if cfg.ColdStart {
+		if _, ok := querier.(sessionQuerier); !ok {
+			return Result{}, errors.New("-cold-start needs a Cassandra session")
+		}
+		// Measure on a fresh session instead of the warm connection pool,
+		// like a client that reconnects per invocation. The caller's
+		// session is left open for the caller to close.
+		cfg.printf("Creating a new session for a cold start...\n")
+		cold, err := CreateSession(cfg)
+		if err != nil {
+			return Result{}, fmt.Errorf("reconnecting for -cold-start: %w", err)
+		}
+		defer cold.Close()
+		bench.querier = sessionQuerier{cold}
+		if tracer != nil {
+			bench.tracer = gocql.NewTraceWriter(cold, cfg.TraceOut)
+		}
+	}
+
+	var result Result
+	if cfg.SLAP99Ms > 0 {
+		result = bench.findSLARate()
+	} else if len(cfg.RateSchedule) > 0 {
+		result = bench.runRateSchedule()
+	} else if len(cfg.Phases) > 0 {
+		result = bench.runPhases()
+	} else if len(cfg.SweepConsistency) > 0 {
+		result = bench.sweepConsistency(runOptions{Schedule: schedule})
+	} else if cfg.NoPrepare {
+		// Run a prepared reference pass first so the unprepared numbers can
+		// be reported as a delta against the default execution path.
+		cfg.printf("Running prepared reference pass...\n")
+		reference := bench.run(runOptions{})
+		if reference.StoppedEarly {
+			return reference, abortCause(ctx)
+		}
+		cfg.printf("Running unprepared pass...\n")
+		result = bench.run(runOptions{Unprepared: true})
+		result.PreparedAvgLatencyMs = reference.AvgLatencyMs
+		result.UnpreparedDeltaMs = result.AvgLatencyMs - reference.AvgLatencyMs
+	} else if cfg.Repeat > 1 {
+		result = bench.repeatRuns(runOptions{Schedule: schedule})
+	} else {
+		result = bench.run(runOptions{Schedule: schedule})
+	}
+
+	if cfg.Prime {
+		result.PrimeSeconds = prime.TotalSeconds
+		result.PrimeHitRatePct = prime.HitRatePct
+	}
+	if bench.writes != nil {
+		result.WriteCheck = bench.writes.verify(querier, cfg)
+	}
+	result.timeline = bench.timeline
+	return result, abortCause(ctx)
+}
+
+// printSampleQuery prints the first query of the run with key's values
+// inlined, for -print-sample-query. It is a debugging aid for pasting into
+// cqlsh: gocql sends the values as bound parameters, not as literals, and
+// a -date-range bucket or -targets table is picked per query, so they are
+// only an example here.
+func printSampleQuery(cfg Config, key QueryKey) {
+	table := defaultTable
+	if len(cfg.Targets) > 0 {
+		table = cfg.Targets[0].Name
+	}
+	stmt := pointReadStmt(table, cfg)
+	values := key.bindValues()
+	insert := len(cfg.Phases) > 0 && cfg.Phases[0].Op == "write"
+	if insert {
+		stmt = insertStmt(table, cfg)
+	} else if cfg.PartitionOnly {
+		values = values[:1]
+	}
+	if cfg.BucketColumn != "" {
+		bucket := key.Bucket
+		if bucket == "" && len(cfg.Buckets) > 0 {
+			bucket = cfg.Buckets[len(cfg.Buckets)-1]
+		}
+		values = append(values, bucket)
+	}
+	if cfg.Limit > 0 && !insert {
+		values = append(values, int32(cfg.Limit))
+	}
+	types := make([]string, len(values))
+	for i, v := range values {
+		types[i] = fmt.Sprintf("%T %v", v, v)
+	}
+	cfg.printf("Sample query (debugging aid: the values are inlined for illustration; gocql binds them as parameters):\n")
+	cfg.printf("  %s;\n", strings.TrimPrefix(unpreparedStmt(stmt, values), unpreparedMarker))
+	cfg.printf("  prepared: %s\n", stmt)
+	cfg.printf("  bound:    [%s]\n", strings.Join(types, ", "))
+}
+
+// abortCause returns the error that made -fail-fast, the -run-retries
+// readiness probe, a schema mismatch or an interrupt cancel ctx, if any.
+func abortCause(ctx context.Context) error {
+	if cause := context.Cause(ctx); IsStopCause(cause) {
+		return cause
+	}
+	return nil
+}
+
+// CreateSession connects to the cluster cfg describes.
+func CreateSession(cfg Config) (*gocql.Session, error) {
+	cluster, err := cfg.Cluster.NewCluster(cfg.Concurrency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cluster configuration: %w", err)
+	}
+	if len(cfg.Targets) > 0 {
+		// Targets are fully qualified, so don't pin the session to one
+		// keyspace.
+		cluster.Keyspace = ""
+	}
+	return cluster.CreateSession()
+}
+
+// loadKeys reads the keys file, or the replay log with -replay. A replay
+// caps cfg.NumQueries at the number of recorded queries and returns their
+// schedule.
+func loadKeys(cfg *Config) ([]QueryKey, []time.Duration, error) {
+	absPath, _ := filepath.Abs(cfg.KeysFilePath)
+	cfg.printf("Reading query keys from %s...\n", absPath)
+	file, err := os.ReadFile(cfg.KeysFilePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading keys file: %w", err)
+	}
+	if cfg.ValidateKeys {
+		var extra []string
+		if cfg.Replay {
+			extra = append(extra, "ts")
+		}
+		if err := validateKeyFields(file, cfg.BestEffort, extra...); err != nil {
+			return nil, nil, fmt.Errorf("keys file does not match the bind fields: %w", err)
+		}
+	}
+
+	var keys []QueryKey
+	var schedule []time.Duration
+	if cfg.Replay {
+		keys, schedule, err = parseReplayLog(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing replay log: %w", err)
+		}
+		// Replay every recorded query once, up to the query count.
+		if len(keys) < cfg.NumQueries {
+			cfg.NumQueries = len(keys)
+		}
+	} else {
+		var skipped int
+		if cfg.KeysFormat == "csv" {
+			keys, skipped, err = parseKeysCSV(file, cfg.CSVColumns, cfg.CSVNoHeader, cfg.BestEffort, cfg.logger())
+		} else {
+			keys, skipped, err = parseKeys(file, cfg.BestEffort, cfg.logger())
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("unmarshalling keys: %w", err)
+		}
+		if skipped > 0 {
+			cfg.logger().Printf("Skipped %d unparseable entries in the keys file.", skipped)
+		}
+	}
+
+	reportKeyCoercions(cfg.logger())
+	if len(keys) == 0 {
+		return nil, nil, fmt.Errorf("no keys found in %s; run the data inserter first", cfg.KeysFilePath)
+	}
+	// A file that is much shorter than expected makes for a misleadingly
+	// fast run, so show what was actually loaded.
+	first, last := keys[0], keys[len(keys)-1]
+	cfg.printf("Loaded %d keys: first eqp_model=%q job_id=%q strtgy_name=%q, last eqp_model=%q job_id=%q strtgy_name=%q\n",
+		len(keys), first.EqpModel, first.JobID, first.StrategyName, last.EqpModel, last.JobID, last.StrategyName)
+	if len(keys) < cfg.MinKeys {
+		return nil, nil, fmt.Errorf("only %d keys loaded from %s, fewer than -min-keys %d; the file may be truncated", len(keys), cfg.KeysFilePath, cfg.MinKeys)
+	}
+	if cfg.MaxPartitions > 0 {
+		n := len(keys)
+		var partitions int
+		keys, partitions = limitPartitions(keys, cfg.MaxPartitions)
+		cfg.printf("Kept %d of %d keys in the first %d of %d partitions (-max-partitions)\n",
+			len(keys), n, min(cfg.MaxPartitions, partitions), partitions)
+	}
+	if err := checkKeyBuckets(keys, *cfg); err != nil {
+		return nil, nil, err
+	}
+	if err := applyKeyTypes(keys, cfg.KeyTypes); err != nil {
+		return nil, nil, fmt.Errorf("converting keys with -key-types: %w", err)
+	}
+	if cfg.InList > 0 {
+		n := len(keys)
+		keys = groupInList(keys, cfg.InList)
+		cfg.printf("Grouped %d keys into %d IN queries of up to %d clustering keys (mean %.1f)\n",
+			n, len(keys), cfg.InList, float64(n)/float64(len(keys)))
+	}
+	if cfg.LeanPointRead() {
+		boxKeyValues(keys)
+	}
+	return keys, schedule, nil
+}
@@ -0,0 +1,69 @@
+package bench
+
+import (
+	"math"
+)
+
+// findSLARate searches for the highest rate whose p99 latency stays under
+// cfg.SLAP99Ms. It runs cfg.SLAProbes short probe phases and adjusts the
+// rate between them with additive increase / multiplicative decrease: a
+// probe that meets the target (and has no failures) raises the rate by a
+// fixed step, a probe that misses it halves the rate. The caller gets the
+// result of the best passing probe with the SLA fields filled in, or, when
+// no probe passed, the last probe's result with SLAMissed set.
+func (b *benchmark) findSLARate() Result {
+	cfg := b.cfg
+	step := cfg.MaxRate / 10
+	rate := step
+	if cfg.Rate > 0 {
+		rate = math.Min(cfg.Rate, cfg.MaxRate)
+	}
+
+	var best, last Result
+	for probe := 1; probe <= cfg.SLAProbes; probe++ {
+		queries := int(rate * cfg.ProbeDuration.Seconds())
+		if queries < 1 {
+			queries = 1
+		}
+		cfg.printf("Probe %d/%d at %.2f queries/second...\n", probe, cfg.SLAProbes, rate)
+		result := b.run(runOptions{Queries: queries, Rate: rate})
+		last = result
+		if result.StoppedEarly {
+			// A truncated probe says nothing about the SLA.
+			best.StoppedEarly = true
+			break
+		}
+		met := result.P99Ms <= cfg.SLAP99Ms && result.FailedQueries == 0
+		verdict := "missed"
+		if met {
+			verdict = "met"
+		}
+		cfg.printf("Probe %d: p99 %.3f ms, failed %d, SLA %s\n", probe, result.P99Ms, result.FailedQueries, verdict)
+
+		if met {
+			if rate > best.SLAMaxRate {
+				best = result
+				best.SLAMaxRate = rate
+			}
+			if rate >= cfg.MaxRate {
+				break
+			}
+			rate = math.Min(rate+step, cfg.MaxRate)
+		} else {
+			rate /= 2
+			// Search more finely around the knee once it has been found.
+			step /= 2
+		}
+	}
+	if best.SLAMaxRate == 0 {
+		stopped := best.StoppedEarly
+		best = last
+		best.StoppedEarly = stopped || last.StoppedEarly
+		if !best.StoppedEarly {
+			cfg.logger().Printf("WARNING: no probe met p99 <= %.3f ms; lower -rate or raise -sla-probes.", cfg.SLAP99Ms)
+			best.SLAMissed = true
+		}
+	}
+	best.SLAP99TargetMs = cfg.SLAP99Ms
+	return best
+}
@@ -0,0 +1,747 @@
+package bench
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// runStats accumulates per-query outcomes from all workers.
+type runStats struct {
+	mu               sync.Mutex
+	completed        int64
+	successful       int64
+	failed           int64
+	deadlineExceeded int64
+	serverTimeouts   int64
+	totalLatency     time.Duration
+
+	// -fast-path outcomes: reads served at LOCAL_ONE, and reads that fell
+	// back to QUORUM with the time the fallback added.
+	fastPath      int64
+	fallbacks     int64
+	fallbackTotal time.Duration
+
+	// Query attempts per coordinator datacenter with -report-dc.
+	dcAttempts map[string]int64
+
+	// Query attempts per coordinator address with -report-hosts.
+	hosts map[string]*hostStats
+
+	// Queries slower than -slow-query-ms.
+	slow int64
+
+	// Estimated bytes scanned, and bound by successful writes, with
+	// -measure-bytes.
+	bytesRead    int64
+	bytesWritten int64
+
+	// App-level retries and queries whose -retry-budget ran out.
+	retries         int64
+	budgetExhausted int64
+
+	// Queries that panicked and were recovered as failures.
+	panics int64
+
+	// Scheduling lag behind a replay schedule.
+	lagSamples int64
+	totalLag   time.Duration
+	maxLag     time.Duration
+
+	// Prepared and unprepared cohorts with -split-prepare, indexed by
+	// whether the query ran unprepared.
+	cohorts [2]*cohortStats
+
+	// nullKeyRows counts scanned rows whose eqp_model came back NULL.
+	nullKeyRows int64
+
+	// partitions holds the distinct partitions queried with
+	// -max-partitions.
+	partitions map[string]struct{}
+
+	// WRITETIME and TTL of -metadata-column.
+	metadata metadataStats
+
+	// Client-side wait versus execution with -pool-wait.
+	poolAttempts  int64
+	poolWaitTotal time.Duration
+	poolWaitMax   time.Duration
+	poolExecTotal time.Duration
+	poolExecMax   time.Duration
+	slotSamples   int64
+	slotWaitTotal time.Duration
+	slotWaitMax   time.Duration
+
+	// Per-phase totals with -phase-timing.
+	phaseSamples int64
+	bindTotal    time.Duration
+	executeTotal time.Duration
+	scanTotal    time.Duration
+
+	// Cold-start split with -cold-start: the very first query, each worker's
+	// first query, and everything after.
+	firstQuery    time.Duration
+	coldSamples   int64
+	coldTotal     time.Duration
+	steadySamples int64
+	steadyTotal   time.Duration
+
+	// Rows returned per partition with -partition-only.
+	rows *hdrhistogram.Histogram
+	// rowLimit is the -limit partition reads were bound with and
+	// limitFilled counts the reads that returned that many rows.
+	rowLimit    int
+	limitFilled int64
+
+	// scanColumns is the widest row scanned, from the result metadata.
+	scanColumns int
+
+	// columnNames is the set of columns -map-scan rows came back with.
+	columnNames map[string]bool
+
+	// errorSamples keeps the first failing keys with -sample-errors-with-keys.
+	errorSamples []ErrorSample
+
+	// groups holds per-dimension, per-group counts for breakdowns.
+	groups map[string]map[string]*groupCounts
+}
+
+// groupCounts accumulates the outcomes of one group in a breakdown.
+type groupCounts struct {
+	completed    int64
+	successful   int64
+	failed       int64
+	totalLatency time.Duration
+}
+
+// label assigns a query to the group name within a breakdown dimension.
+type label struct {
+	dim, name string
+}
+
+// record adds the outcome of a single query.
+// Each label also counts the query towards that label's breakdown group.
+func (s *runStats) record(found bool, err error, latency time.Duration, labels ...label) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, l := range labels {
+		s.group(l).add(found, err, latency)
+	}
+	s.completed++
+	s.totalLatency += latency
+	if found {
+		s.successful++
+	}
+	if err != nil {
+		s.failed++
+		switch {
+		case isDeadlineExceeded(err):
+			s.deadlineExceeded++
+		case isServerTimeout(err):
+			s.serverTimeouts++
+		}
+	}
+}
+
+// cohortStats accumulates one -split-prepare cohort.
+type cohortStats struct {
+	queries int64
+	failed  int64
+	total   time.Duration
+	hist    *hdrhistogram.Histogram
+}
+
+// recordCohort adds a query to the prepared or unprepared -split-prepare
+// cohort.
+func (s *runStats) recordCohort(unprepared bool, err error, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := 0
+	if unprepared {
+		i = 1
+	}
+	c := s.cohorts[i]
+	if c == nil {
+		c = &cohortStats{hist: hdrhistogram.New(minLatencyMicros, maxLatencyMicros, latencySigFigs)}
+		s.cohorts[i] = c
+	}
+	c.queries++
+	c.total += latency
+	if err != nil {
+		c.failed++
+	}
+	c.hist.RecordValue(clampMicros(latency))
+}
+
+// recordFastPath counts a read served by the LOCAL_ONE fast path.
+func (s *runStats) recordFastPath() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fastPath++
+}
+
+// recordFallback counts a read that fell back to QUORUM and the latency the
+// fallback read added.
+func (s *runStats) recordFallback(added time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallbacks++
+	s.fallbackTotal += added
+}
+
+// recordDC counts one query attempt coordinated by a host in dc.
+func (s *runStats) recordDC(dc string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dcAttempts == nil {
+		s.dcAttempts = make(map[string]int64)
+	}
+	s.dcAttempts[dc]++
+}
+
+// recordHost counts one query attempt coordinated by addr and whether it
+// failed.
+func (s *runStats) recordHost(addr, dc string, err error, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hosts == nil {
+		s.hosts = make(map[string]*hostStats)
+	}
+	h := s.hosts[addr]
+	if h == nil {
+		h = &hostStats{dc: dc}
+		s.hosts[addr] = h
+	}
+	h.attempts++
+	h.total += latency
+	if err != nil {
+		h.errors++
+		if isDeadlineExceeded(err) || isServerTimeout(err) {
+			h.timeouts++
+		}
+	}
+}
+
+// recordNullKey counts a scanned row whose eqp_model was NULL.
+func (s *runStats) recordNullKey() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nullKeyRows++
+}
+
+// recordPartition notes the partition a query read.
+func (s *runStats) recordPartition(eqpModel, bucket string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.partitions == nil {
+		s.partitions = make(map[string]struct{})
+	}
+	s.partitions[partitionOf(eqpModel, bucket)] = struct{}{}
+}
+
+// recordSlow counts a query slower than -slow-query-ms.
+func (s *runStats) recordSlow() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slow++
+}
+
+// recordBytes adds the estimated bytes one query scanned.
+func (s *runStats) recordBytes(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bytesRead += n
+}
+
+// recordBytesWritten adds the estimated bytes one successful write bound.
+func (s *runStats) recordBytesWritten(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bytesWritten += n
+}
+
+// recordRetry counts one app-level retry of a failed attempt.
+func (s *runStats) recordRetry() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retries++
+}
+
+// recordPanic counts a query that panicked. The query itself is recorded
+// as a failure with record.
+func (s *runStats) recordPanic() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.panics++
+}
+
+// recordBudgetExhausted counts a query that failed because its
+// -retry-budget ran out.
+func (s *runStats) recordBudgetExhausted() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.budgetExhausted++
+}
+
+// recordLag adds how late a scheduled query started.
+func (s *runStats) recordLag(lag time.Duration) {
+	if lag < 0 {
+		lag = 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lagSamples++
+	s.totalLag += lag
+	if lag > s.maxLag {
+		s.maxLag = lag
+	}
+}
+
+// recordPoolWait adds one attempt's time inside the driver before the
+// request went out and its time executing on the connection.
+func (s *runStats) recordPoolWait(wait, exec time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.poolAttempts++
+	s.poolWaitTotal += wait
+	s.poolExecTotal += exec
+	if wait > s.poolWaitMax {
+		s.poolWaitMax = wait
+	}
+	if exec > s.poolExecMax {
+		s.poolExecMax = exec
+	}
+}
+
+// recordSlotWait adds how long a -rate query waited past its due time for
+// a free worker.
+func (s *runStats) recordSlotWait(wait time.Duration) {
+	if wait < 0 {
+		wait = 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slotSamples++
+	s.slotWaitTotal += wait
+	if wait > s.slotWaitMax {
+		s.slotWaitMax = wait
+	}
+}
+
+// recordPhases adds the client-side phase split of one query.
+func (s *runStats) recordPhases(bind, execute, scan time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.phaseSamples++
+	s.bindTotal += bind
+	s.executeTotal += execute
+	s.scanTotal += scan
+}
+
+// recordColdStart classifies a query for the cold-start report. first marks
+// the first query of the pass, workerFirst the first one of its worker.
+func (s *runStats) recordColdStart(first, workerFirst bool, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if first {
+		s.firstQuery = latency
+	}
+	if workerFirst {
+		s.coldSamples++
+		s.coldTotal += latency
+	} else {
+		s.steadySamples++
+		s.steadyTotal += latency
+	}
+}
+
+// recordRows adds the number of rows one partition read returned. A
+// positive limit is the -limit the read was bound with; reads that came
+// back with that many rows are counted as filled.
+func (s *runStats) recordRows(n, limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rows == nil {
+		s.rows = hdrhistogram.New(1, 1<<31, latencySigFigs)
+	}
+	s.rows.RecordValue(int64(n))
+	s.rowLimit = limit
+	if limit > 0 && n >= limit {
+		s.limitFilled++
+	}
+}
+
+// recordColumns notes the number of columns a query's rows were scanned
+// with.
+func (s *runStats) recordColumns(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n > s.scanColumns {
+		s.scanColumns = n
+	}
+}
+
+// recordColumnNames notes the columns of a -map-scan row.
+func (s *runStats) recordColumnNames(row map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.columnNames == nil {
+		s.columnNames = make(map[string]bool)
+	}
+	for name := range row {
+		s.columnNames[name] = true
+	}
+	s.scanColumns = max(s.scanColumns, len(row))
+}
+
+// recordErrorSample keeps the failing key and its error, up to limit
+// samples, so mass failures cannot grow memory without bound.
+func (s *runStats) recordErrorSample(limit int, target string, key QueryKey, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.errorSamples) < limit {
+		s.errorSamples = append(s.errorSamples, ErrorSample{Target: target, Key: key, Error: err.Error()})
+	}
+}
+
+func (s *runStats) group(l label) *groupCounts {
+	if s.groups == nil {
+		s.groups = make(map[string]map[string]*groupCounts)
+	}
+	byName := s.groups[l.dim]
+	if byName == nil {
+		byName = make(map[string]*groupCounts)
+		s.groups[l.dim] = byName
+	}
+	g := byName[l.name]
+	if g == nil {
+		g = &groupCounts{}
+		byName[l.name] = g
+	}
+	return g
+}
+
+func (g *groupCounts) add(found bool, err error, latency time.Duration) {
+	g.completed++
+	g.totalLatency += latency
+	if found {
+		g.successful++
+	}
+	if err != nil {
+		g.failed++
+	}
+}
+
+// merge adds everything o collected, so passes that ran one after another,
+// such as -chunk-size chunks or -phases, can be summarized as one. At most
+// sampleLimit error samples are kept.
+func (s *runStats) merge(o *runStats, sampleLimit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	s.completed += o.completed
+	s.successful += o.successful
+	s.failed += o.failed
+	s.deadlineExceeded += o.deadlineExceeded
+	s.serverTimeouts += o.serverTimeouts
+	s.totalLatency += o.totalLatency
+
+	s.fastPath += o.fastPath
+	s.fallbacks += o.fallbacks
+	s.fallbackTotal += o.fallbackTotal
+
+	for dc, n := range o.dcAttempts {
+		if s.dcAttempts == nil {
+			s.dcAttempts = make(map[string]int64)
+		}
+		s.dcAttempts[dc] += n
+	}
+	for addr, oh := range o.hosts {
+		if s.hosts == nil {
+			s.hosts = make(map[string]*hostStats)
+		}
+		h := s.hosts[addr]
+		if h == nil {
+			h = &hostStats{dc: oh.dc}
+			s.hosts[addr] = h
+		}
+		h.attempts += oh.attempts
+		h.errors += oh.errors
+		h.timeouts += oh.timeouts
+		h.total += oh.total
+	}
+
+	s.slow += o.slow
+	s.bytesRead += o.bytesRead
+	s.bytesWritten += o.bytesWritten
+	s.retries += o.retries
+	s.budgetExhausted += o.budgetExhausted
+	s.panics += o.panics
+
+	s.lagSamples += o.lagSamples
+	s.totalLag += o.totalLag
+	s.maxLag = max(s.maxLag, o.maxLag)
+
+	for i, oc := range o.cohorts {
+		if oc == nil {
+			continue
+		}
+		c := s.cohorts[i]
+		if c == nil {
+			c = &cohortStats{hist: hdrhistogram.New(minLatencyMicros, maxLatencyMicros, latencySigFigs)}
+			s.cohorts[i] = c
+		}
+		c.queries += oc.queries
+		c.failed += oc.failed
+		c.total += oc.total
+		c.hist.Merge(oc.hist)
+	}
+
+	s.nullKeyRows += o.nullKeyRows
+	for p := range o.partitions {
+		if s.partitions == nil {
+			s.partitions = make(map[string]struct{})
+		}
+		s.partitions[p] = struct{}{}
+	}
+	s.metadata.merge(o.metadata)
+
+	s.poolAttempts += o.poolAttempts
+	s.poolWaitTotal += o.poolWaitTotal
+	s.poolWaitMax = max(s.poolWaitMax, o.poolWaitMax)
+	s.poolExecTotal += o.poolExecTotal
+	s.poolExecMax = max(s.poolExecMax, o.poolExecMax)
+	s.slotSamples += o.slotSamples
+	s.slotWaitTotal += o.slotWaitTotal
+	s.slotWaitMax = max(s.slotWaitMax, o.slotWaitMax)
+
+	s.phaseSamples += o.phaseSamples
+	s.bindTotal += o.bindTotal
+	s.executeTotal += o.executeTotal
+	s.scanTotal += o.scanTotal
+
+	// The very first query is the first pass's.
+	if s.coldSamples == 0 {
+		s.firstQuery = o.firstQuery
+	}
+	s.coldSamples += o.coldSamples
+	s.coldTotal += o.coldTotal
+	s.steadySamples += o.steadySamples
+	s.steadyTotal += o.steadyTotal
+
+	if o.rows != nil {
+		if s.rows == nil {
+			s.rows = hdrhistogram.New(1, 1<<31, latencySigFigs)
+		}
+		s.rows.Merge(o.rows)
+		s.rowLimit = o.rowLimit
+		s.limitFilled += o.limitFilled
+	}
+
+	s.scanColumns = max(s.scanColumns, o.scanColumns)
+	for name := range o.columnNames {
+		if s.columnNames == nil {
+			s.columnNames = make(map[string]bool)
+		}
+		s.columnNames[name] = true
+	}
+	for _, e := range o.errorSamples {
+		if len(s.errorSamples) < sampleLimit {
+			s.errorSamples = append(s.errorSamples, e)
+		}
+	}
+
+	for dim, byName := range o.groups {
+		for name, og := range byName {
+			g := s.group(label{dim, name})
+			g.completed += og.completed
+			g.successful += og.successful
+			g.failed += og.failed
+			g.totalLatency += og.totalLatency
+		}
+	}
+}
+
+// result returns the counters collected so far as a Result. Timing fields
+// are left for the caller to fill in.
+func (s *runStats) result(cfg Config) Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := Result{
+		Tag:               cfg.Tag,
+		Concurrency:       cfg.Concurrency,
+		Queries:           cfg.NumQueries,
+		PinnedHost:        cfg.Cluster.PinHost,
+		CoalesceWait:      cfg.Cluster.CoalesceWait,
+		CompletedQueries:  s.completed,
+		SuccessfulQueries: s.successful,
+		HitRatePct:        percent(s.successful, s.completed),
+		FailedQueries:     s.failed,
+		DeadlineExceeded:  s.deadlineExceeded,
+		ServerTimeouts:    s.serverTimeouts,
+		TimeoutRatePct:    percent(s.deadlineExceeded, s.completed),
+		AvgLatencyMs:      avgMs(s.totalLatency, s.completed),
+	}
+	if s.phaseSamples > 0 {
+		r.Phases = &PhaseTiming{
+			BindMs:    avgMs(s.bindTotal, s.phaseSamples),
+			ExecuteMs: avgMs(s.executeTotal, s.phaseSamples),
+			ScanMs:    avgMs(s.scanTotal, s.phaseSamples),
+		}
+	}
+	if s.coldSamples > 0 {
+		r.ColdStart = &ColdStartResult{
+			FirstQueryMs:     avgMs(s.firstQuery, 1),
+			WorkerFirstAvgMs: avgMs(s.coldTotal, s.coldSamples),
+			SteadyStateAvgMs: avgMs(s.steadyTotal, s.steadySamples),
+		}
+	}
+	r.ScanColumns = s.scanColumns
+	if len(s.columnNames) > 0 {
+		r.ColumnNames = slices.Sorted(maps.Keys(s.columnNames))
+	}
+	r.ErrorSamples = append([]ErrorSample(nil), s.errorSamples...)
+	// Report samples in key order rather than in the order workers hit them.
+	sort.Slice(r.ErrorSamples, func(i, j int) bool {
+		a, b := r.ErrorSamples[i], r.ErrorSamples[j]
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		if a.Key.EqpModel != b.Key.EqpModel {
+			return a.Key.EqpModel < b.Key.EqpModel
+		}
+		if a.Key.JobID != b.Key.JobID {
+			return a.Key.JobID < b.Key.JobID
+		}
+		return a.Key.StrategyName < b.Key.StrategyName
+	})
+	if s.rows != nil {
+		rows := &RowsDistribution{
+			Min:  s.rows.Min(),
+			Mean: s.rows.Mean(),
+			P50:  s.rows.ValueAtQuantile(50),
+			P99:  s.rows.ValueAtQuantile(99),
+			Max:  s.rows.Max(),
+		}
+		if s.rowLimit > 0 {
+			rows.Limit = s.rowLimit
+			rows.LimitFilledPct = float64(s.limitFilled) / float64(s.rows.TotalCount()) * 100
+		}
+		if cfg.InList > 0 {
+			r.RowsPerQuery = rows
+		} else {
+			r.RowsPerPartition = rows
+		}
+	}
+	if len(s.hosts) > 0 {
+		r.Hosts = hostResults(s.hosts)
+	}
+	r.Retries = s.retries
+	r.SlowQueries = s.slow
+	if len(s.dcAttempts) > 0 {
+		r.CoordinatorDCs = make(map[string]int64, len(s.dcAttempts))
+		var total, remote int64
+		for dc, n := range s.dcAttempts {
+			r.CoordinatorDCs[dc] = n
+			total += n
+			if dc != cfg.Cluster.LocalDC {
+				remote += n
+			}
+		}
+		if cfg.Cluster.LocalDC != "" {
+			r.LocalDC = cfg.Cluster.LocalDC
+			r.CrossDCPct = percent(remote, total)
+		}
+	}
+	if cfg.FastPath {
+		r.FastPath = &FastPathResult{
+			SuccessPct:    percent(s.fastPath, s.fastPath+s.fallbacks),
+			Fallbacks:     s.fallbacks,
+			FallbackAvgMs: avgMs(s.fallbackTotal, s.fallbacks),
+		}
+	}
+	if cfg.MeasureBytes && s.completed > 0 {
+		r.BytesRead = s.bytesRead
+		r.BytesReadPerQuery = float64(s.bytesRead) / float64(s.completed)
+		r.BytesWritten = s.bytesWritten
+	}
+	r.RetryBudgetExhausted = s.budgetExhausted
+	r.Panics = s.panics
+	if s.lagSamples > 0 {
+		r.ScheduleLagAvgMs = avgMs(s.totalLag, s.lagSamples)
+		r.ScheduleLagMaxMs = avgMs(s.maxLag, 1)
+	}
+	r.NullKeyRows = s.nullKeyRows
+	r.PartitionsQueried = len(s.partitions)
+	r.Metadata = s.metadata.result(cfg.MetadataColumn, cfg.ExpiryWindow)
+	for i, c := range s.cohorts {
+		if c == nil {
+			continue
+		}
+		r.PrepareSplit = append(r.PrepareSplit, CohortResult{
+			Name:          [2]string{"prepared", "unprepared"}[i],
+			Queries:       c.queries,
+			FailedQueries: c.failed,
+			AvgLatencyMs:  avgMs(c.total, c.queries),
+			P50Ms:         microsToMs(c.hist.ValueAtQuantile(50)),
+			P90Ms:         microsToMs(c.hist.ValueAtQuantile(90)),
+			P99Ms:         microsToMs(c.hist.ValueAtQuantile(99)),
+			MaxMs:         microsToMs(c.hist.Max()),
+		})
+	}
+	if s.poolAttempts > 0 {
+		r.PoolWait = &PoolWaitResult{
+			Attempts:  s.poolAttempts,
+			WaitAvgMs: avgMs(s.poolWaitTotal, s.poolAttempts),
+			WaitMaxMs: avgMs(s.poolWaitMax, 1),
+			ExecAvgMs: avgMs(s.poolExecTotal, s.poolAttempts),
+			ExecMaxMs: avgMs(s.poolExecMax, 1),
+		}
+		if s.slotSamples > 0 {
+			r.PoolWait.SlotWaitAvgMs = avgMs(s.slotWaitTotal, s.slotSamples)
+			r.PoolWait.SlotWaitMaxMs = avgMs(s.slotWaitMax, 1)
+		}
+	}
+	for dim, byName := range s.groups {
+		if r.Breakdowns == nil {
+			r.Breakdowns = make(map[string][]GroupResult)
+		}
+		groups := make([]GroupResult, 0, len(byName))
+		for name, g := range byName {
+			groups = append(groups, GroupResult{
+				Name:              name,
+				Queries:           g.completed,
+				SuccessfulQueries: g.successful,
+				SharePct:          percent(g.completed, s.completed),
+				HitRatePct:        percent(g.successful, g.completed),
+				FailedQueries:     g.failed,
+				AvgLatencyMs:      avgMs(g.totalLatency, g.completed),
+			})
+		}
+		sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+		r.Breakdowns[dim] = groups
+	}
+	return r
+}
+
+// percent returns n as a percentage of total, or 0 when total is 0.
+func percent(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100
+}
+
+// avgMs returns the mean of total over n samples in milliseconds.
+func avgMs(total time.Duration, n int64) float64 {
+	if n == 0 {
+		return 0
+	}
+	return float64(total) / float64(n) / float64(time.Millisecond)
+}
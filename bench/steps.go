@@ -0,0 +1,81 @@
+package bench
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateStep is one entry of a -rate-schedule spec.
+type RateStep struct {
+	Rate     float64
+	Duration time.Duration
+}
+
+// ParseRateSchedule parses a spec such as "1000:30s,2000:30s,4000:30s":
+// a rate in queries per second and how long to hold it, per step.
+func ParseRateSchedule(spec string) ([]RateStep, error) {
+	var steps []RateStep
+	for _, part := range SplitList(spec) {
+		rateStr, durStr, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("expected RATE:DURATION, got %q", part)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rateStr), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid rate %q", rateStr)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(durStr))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid duration %q for rate %s", durStr, rateStr)
+		}
+		steps = append(steps, RateStep{Rate: rate, Duration: d})
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("no steps in %q", spec)
+	}
+	return steps, nil
+}
+
+// runRateSchedule runs one pass per -rate-schedule step, each at the
+// step's rate for roughly its duration, to find the knee of the latency
+// curve in one invocation. The caller gets the last step's result with
+// every step attached.
+func (b *benchmark) runRateSchedule() Result {
+	steps := b.cfg.RateSchedule
+
+	var result Result
+	var summaries []StepResult
+	for i, step := range steps {
+		queries := int(step.Rate * step.Duration.Seconds())
+		if queries < 1 {
+			queries = 1
+		}
+		b.cfg.printf("=== Step %d/%d: %.2f queries/second for %v (%d queries) ===\n", i+1, len(steps), step.Rate, step.Duration, queries)
+		result = b.run(runOptions{Queries: queries, Rate: step.Rate})
+		s := StepResult{
+			Step:          i + 1,
+			TargetRate:    step.Rate,
+			QPS:           result.QPS,
+			Queries:       result.CompletedQueries,
+			FailedQueries: result.FailedQueries,
+			AvgLatencyMs:  result.AvgLatencyMs,
+			P50Ms:         result.P50Ms,
+			P90Ms:         result.P90Ms,
+			P99Ms:         result.P99Ms,
+			MaxMs:         result.MaxMs,
+		}
+		if s.Queries > 0 {
+			s.ErrorRatePct = float64(s.FailedQueries) / float64(s.Queries) * 100
+		}
+		b.cfg.printf("=== Step %d: %.2f queries/second, p50 %.3f ms, p99 %.3f ms, errors %.2f%% ===\n",
+			s.Step, s.QPS, s.P50Ms, s.P99Ms, s.ErrorRatePct)
+		summaries = append(summaries, s)
+		if result.StoppedEarly {
+			break
+		}
+	}
+	result.Steps = summaries
+	return result
+}
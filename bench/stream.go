@@ -0,0 +1,75 @@
+package bench
+
+import (
+	"fmt"
+)
+
+// keyStreamBuffer is how many decoded keys -stream-keys keeps ready ahead of
+// the workers. It only needs to cover bursts; the producer otherwise reads
+// as fast as the workers consume.
+const keyStreamBuffer = 4096
+
+// runStreamed runs the workload while a producer goroutine decodes the keys
+// file, so the first query goes out as soon as the first key is read rather
+// than after the whole file is loaded. Each key is queried once, until the
+// file or cfg.NumQueries runs out; the pass ends when the producer is done
+// and the workers have drained what it sent.
+func (b *benchmark) runStreamed() (Result, error) {
+	cfg := b.cfg
+	chunker, err := openKeyChunker(cfg.KeysFilePath, cfg.BestEffort, cfg.logger())
+	if err != nil {
+		return Result{}, fmt.Errorf("reading keys file: %w", err)
+	}
+	defer chunker.Close()
+
+	keys := make(chan QueryKey, keyStreamBuffer)
+	produced := make(chan struct{})
+	var readErr error
+	go func() {
+		defer close(produced)
+		defer close(keys)
+		for remaining := cfg.NumQueries; remaining > 0; {
+			batch, err := chunker.next(min(keyStreamBuffer, remaining))
+			if err == nil {
+				err = checkKeyBuckets(batch, cfg)
+			}
+			if err == nil {
+				err = applyKeyTypes(batch, cfg.KeyTypes)
+			}
+			if err == nil && cfg.LeanPointRead() {
+				boxKeyValues(batch)
+			}
+			if err != nil {
+				// Stop the workers too; the pass is reported as failed.
+				readErr = err
+				b.abort(err)
+				return
+			}
+			if len(batch) == 0 {
+				return
+			}
+			for _, key := range batch {
+				select {
+				case keys <- key:
+				case <-b.ctx.Done():
+					return
+				}
+			}
+			remaining -= len(batch)
+		}
+	}()
+
+	result := b.run(runOptions{Keys: keys})
+	<-produced
+	reportKeyCoercions(cfg.logger())
+	if chunker.skipped > 0 {
+		cfg.logger().Printf("Skipped %d unparseable entries in the keys file.", chunker.skipped)
+	}
+	if readErr != nil {
+		return Result{}, fmt.Errorf("reading keys file: %w", readErr)
+	}
+	if result.Queries == 0 && !result.StoppedEarly {
+		return Result{}, fmt.Errorf("no keys found in %s; run the data inserter first", cfg.KeysFilePath)
+	}
+	return result, nil
+}
@@ -0,0 +1,57 @@
+package bench
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultTable is queried in the session keyspace when no -targets are given.
+const defaultTable = "test_table"
+
+// Target is a table that receives a weighted share of the queries.
+type Target struct {
+	Name   string
+	Weight float64
+	stmt   string
+}
+
+// ParseTargets parses a -targets spec of the form
+// "ks1.table:3,ks2.table:1". The weight is optional and defaults to 1.
+func ParseTargets(spec string) ([]Target, error) {
+	var targets []Target
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, weightStr, hasWeight := strings.Cut(part, ":")
+		weight := 1.0
+		if hasWeight {
+			var err error
+			weight, err = strconv.ParseFloat(weightStr, 64)
+			if err != nil || weight <= 0 {
+				return nil, fmt.Errorf("invalid weight %q for target %q", weightStr, name)
+			}
+		}
+		ks, table, qualified := strings.Cut(name, ".")
+		if !qualified || ks == "" || table == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid target %q, expected keyspace.table", name)
+		}
+		targets = append(targets, Target{Name: name, Weight: weight})
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets in %q", spec)
+	}
+	return targets, nil
+}
+
+// newTargetPicker returns a picker that chooses targets in proportion to
+// their weights.
+func newTargetPicker(targets []Target) weightedPicker {
+	weights := make([]float64, len(targets))
+	for i, t := range targets {
+		weights[i] = t.Weight
+	}
+	return newWeightedPicker(weights)
+}
@@ -0,0 +1,36 @@
+package bench
+
+import (
+	"sync"
+	"time"
+)
+
+// logThrottle lets at most one log line through per interval, so a
+// sustained problem (say, every query being slow) doesn't flood the output.
+// Lines that were held back are counted and reported with the next one.
+type logThrottle struct {
+	mu         sync.Mutex
+	interval   time.Duration
+	last       time.Time
+	suppressed int
+}
+
+func newLogThrottle(interval time.Duration) *logThrottle {
+	return &logThrottle{interval: interval}
+}
+
+// allow reports whether a line may be logged now and, if so, how many lines
+// were suppressed since the previous one.
+func (t *logThrottle) allow() (bool, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if !t.last.IsZero() && now.Sub(t.last) < t.interval {
+		t.suppressed++
+		return false, 0
+	}
+	t.last = now
+	suppressed := t.suppressed
+	t.suppressed = 0
+	return true, suppressed
+}
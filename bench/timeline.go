@@ -0,0 +1,95 @@
+package bench
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// timeline counts completions, errors and the in-flight gauge per second
+// of the measured passes for -timeline-file, so ramp-up, steady state and
+// dips can be plotted. Seconds count from the start of the first measured
+// pass and run on across passes, so a -rate-schedule or -repeat run is
+// one continuous curve.
+type timeline struct {
+	mu      sync.Mutex
+	start   time.Time
+	seconds []timelineSecond
+}
+
+type timelineSecond struct {
+	completed     int64
+	errors        int64
+	inFlightSum   int64
+	inFlightCount int64
+}
+
+// begin marks the start of a measured pass; only the first one sets the
+// timeline's origin.
+func (t *timeline) begin() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+}
+
+// at returns the bucket for now, growing the timeline as needed. t.mu must
+// be held.
+func (t *timeline) at(now time.Time) *timelineSecond {
+	i := int(now.Sub(t.start) / time.Second)
+	for len(t.seconds) <= i {
+		t.seconds = append(t.seconds, timelineSecond{})
+	}
+	return &t.seconds[i]
+}
+
+// record counts a completed query.
+func (t *timeline) record(failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.at(time.Now())
+	s.completed++
+	if failed {
+		s.errors++
+	}
+}
+
+// sampleInFlight adds an in-flight gauge reading to the current second.
+func (t *timeline) sampleInFlight(n int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.at(time.Now())
+	s.inFlightSum += n
+	s.inFlightCount++
+}
+
+// WriteTimeline writes the timeline of r as CSV for -timeline-file: the
+// second since the start, completions (the second's QPS), errors and the
+// mean in-flight count. The last second is usually partial. It writes
+// nothing unless the run had Config.Timeline set.
+func (r Result) WriteTimeline(w io.Writer) error {
+	if r.timeline == nil {
+		return nil
+	}
+	return r.timeline.write(w)
+}
+
+// write writes the timeline as WriteTimeline describes.
+func (t *timeline) write(w io.Writer) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var buf bytes.Buffer
+	buf.WriteString("second,qps,errors,inflight\n")
+	for i, s := range t.seconds {
+		inFlight := 0.0
+		if s.inFlightCount > 0 {
+			inFlight = float64(s.inFlightSum) / float64(s.inFlightCount)
+		}
+		fmt.Fprintf(&buf, "%d,%d,%d,%.2f\n", i, s.completed, s.errors, inFlight)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
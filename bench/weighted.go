@@ -0,0 +1,34 @@
+package bench
+
+import "math/rand"
+
+// weightedPicker picks indexes in proportion to a fixed set of weights.
+type weightedPicker struct {
+	cumulative []float64
+}
+
+func newWeightedPicker(weights []float64) weightedPicker {
+	p := weightedPicker{cumulative: make([]float64, len(weights))}
+	var sum float64
+	for i, w := range weights {
+		sum += w
+		p.cumulative[i] = sum
+	}
+	return p
+}
+
+// pick returns an index using the caller's rand source, so each worker can
+// pick without contending on a shared one.
+func (p weightedPicker) pick(rng *rand.Rand) int {
+	n := len(p.cumulative)
+	if n == 1 {
+		return 0
+	}
+	x := rng.Float64() * p.cumulative[n-1]
+	for i, c := range p.cumulative {
+		if x < c {
+			return i
+		}
+	}
+	return n - 1
+}
@@ -0,0 +1,102 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// writeCheckStmt is the non-idempotent -write-mix write: a counter
+// increment, which a retry applies a second time. %s is -counter-table,
+// which must exist with this schema:
+//
+//	CREATE TABLE write_check (
+//	    run_id text,
+//	    key text,
+//	    applied counter,
+//	    PRIMARY KEY ((run_id, key))
+//	)
+const writeCheckStmt = "UPDATE %s SET applied = applied + 1 WHERE run_id = ? AND key = ?"
+
+// writeReadbackStmt reads a -write-mix counter back after the run.
+const writeReadbackStmt = "SELECT applied FROM %s WHERE run_id = ? AND key = ?"
+
+// writeReadbackTimeout bounds each read-back query.
+const writeReadbackTimeout = 10 * time.Second
+
+// writeLedger counts the logical -write-mix writes issued per key, each of
+// which should add exactly one to the key's counter however it was
+// retried. Every run gets a fresh run ID, so the counters start at zero.
+type writeLedger struct {
+	runID string
+	table string
+
+	mu     sync.Mutex
+	issued map[string]int64
+}
+
+func newWriteLedger(table string) *writeLedger {
+	return &writeLedger{
+		runID:  strconv.FormatInt(time.Now().UnixNano(), 36),
+		table:  table,
+		issued: make(map[string]int64),
+	}
+}
+
+// writeKey is the counter key a write for k increments.
+func writeKey(k QueryKey) string {
+	return k.EqpModel + "/" + k.JobID + "/" + k.StrategyName
+}
+
+// issue records one logical write for key and returns the statement and
+// values to send.
+func (l *writeLedger) issue(key string) (string, []interface{}) {
+	l.mu.Lock()
+	l.issued[key]++
+	l.mu.Unlock()
+	return fmt.Sprintf(writeCheckStmt, l.table), []interface{}{l.runID, key}
+}
+
+// verify reads every written counter back at consistency ALL and counts
+// the increments applied more than once (a retried write that had already
+// landed) and the ones that never landed (failed writes). Reads use their
+// own deadline, so an interrupted run is still checked. Progress and
+// warnings go to cfg.Out and cfg.Log.
+func (l *writeLedger) verify(q Querier, cfg Config) *WriteCheckResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r := &WriteCheckResult{Table: l.table, RunID: l.runID, Keys: len(l.issued)}
+	cfg.printf("Reading back %d -write-mix counters from %s...\n", len(l.issued), l.table)
+	stmt := fmt.Sprintf(writeReadbackStmt, l.table)
+	for key, issued := range l.issued {
+		r.Writes += issued
+		ctx, cancel := context.WithTimeout(context.Background(), writeReadbackTimeout)
+		var applied int64
+		iter := q.Query(stmt, l.runID, key).Consistency(gocql.All).WithContext(ctx).Iter()
+		iter.Scan(&applied)
+		err := iter.Close()
+		cancel()
+		if err != nil {
+			r.ReadErrors++
+			continue
+		}
+		r.Applied += applied
+		if applied > issued {
+			r.DoubleApplied += applied - issued
+			r.DoubleAppliedKeys++
+		} else {
+			r.NotApplied += issued - applied
+		}
+	}
+	if r.ReadErrors > 0 {
+		cfg.logger().Printf("WARNING: %d -write-mix counters could not be read back at ALL; the write check is incomplete.", r.ReadErrors)
+	}
+	if r.DoubleApplied > 0 {
+		cfg.logger().Printf("WARNING: %d writes were applied more than once across %d keys; retries are re-sending non-idempotent writes.", r.DoubleApplied, r.DoubleAppliedKeys)
+	}
+	return r
+}
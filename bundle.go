@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runBundle is everything needed to repeat a run: the flags it was started
+// with, its positional arguments, the resolved -seed and a hash of the keys
+// file. A -cluster-config file is recorded by path, not by content.
+type runBundle struct {
+	Created    time.Time         `json:"created"`
+	Flags      map[string]string `json:"flags"`
+	Args       []string          `json:"args"`
+	Seed       int64             `json:"seed"`
+	KeysFile   string            `json:"keys_file,omitempty"`
+	KeysSHA256 string            `json:"keys_sha256,omitempty"`
+}
+
+// bundleSkipFlags are never written to a bundle: the bundle flags
+// themselves, and -password so no secret ends up in a shared file. The
+// credentials of -socks-proxy are stripped by withoutProxyAuth for the
+// same reason.
+var bundleSkipFlags = map[string]bool{
+	"save-bundle":    true,
+	"seed-from-file": true,
+	"password":       true,
+}
+
+// writeBundle saves the flags given on the command line, with -seed
+// replaced by the seed the run actually uses, to path.
+func writeBundle(path string, cfg config) error {
+	b := runBundle{
+		Created:  time.Now().UTC(),
+		Flags:    make(map[string]string),
+		Args:     flag.Args(),
+		Seed:     cfg.Seed,
+		KeysFile: cfg.KeysFilePath,
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch {
+		case bundleSkipFlags[f.Name]:
+		case f.Name == "socks-proxy":
+			b.Flags[f.Name] = withoutProxyAuth(f.Value.String())
+		default:
+			b.Flags[f.Name] = f.Value.String()
+		}
+	})
+	b.Flags["seed"] = strconv.FormatInt(cfg.Seed, 10)
+	if cfg.KeysFilePath != "" {
+		sum, err := fileSHA256(cfg.KeysFilePath)
+		if err != nil {
+			return fmt.Errorf("hashing keys file: %w", err)
+		}
+		b.KeysSHA256 = sum
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeOutputFile(path, append(data, '\n'))
+}
+
+// withoutProxyAuth returns a -socks-proxy spec with any user and password
+// removed, so the bundle keeps the proxy address but not its credentials.
+func withoutProxyAuth(spec string) string {
+	if !strings.Contains(spec, "://") {
+		return spec
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		// There is no telling which part is the password.
+		return ""
+	}
+	u.User = nil
+	return u.String()
+}
+
+// loadBundle reads a bundle written by writeBundle.
+func loadBundle(path string) (*runBundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var b runBundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &b, nil
+}
+
+// apply sets every bundled flag not given on the command line, and the
+// bundled positional arguments when none were given. It must run right
+// after flag.Parse.
+func (b *runBundle) apply() error {
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	for name, value := range b.Flags {
+		if given[name] || bundleSkipFlags[name] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("flag -%s: %w", name, err)
+		}
+	}
+	if flag.NArg() == 0 && len(b.Args) > 0 {
+		return flag.CommandLine.Parse(append([]string{"--"}, b.Args...))
+	}
+	return nil
+}
+
+// checkKeys warns when the keys file at path differs from the one the
+// bundle was saved with, since the rerun would then read different keys.
+func (b *runBundle) checkKeys(path string) {
+	if b.KeysSHA256 == "" || path == "" {
+		return
+	}
+	sum, err := fileSHA256(path)
+	if err != nil {
+		log.Printf("WARNING: cannot hash keys file %s to compare with the bundle: %v", path, err)
+		return
+	}
+	if sum != b.KeysSHA256 {
+		log.Printf("WARNING: ********************************************************")
+		log.Printf("WARNING: keys file %s does not match the bundle.", path)
+		log.Printf("WARNING: bundle sha256 %s (%s)", b.KeysSHA256, b.KeysFile)
+		log.Printf("WARNING: current sha256 %s", sum)
+		log.Printf("WARNING: this run is NOT a reproduction of the bundled run.")
+		log.Printf("WARNING: ********************************************************")
+	}
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
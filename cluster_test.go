@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"cassandra-test/bench"
+)
+
+// parseArgs runs parseConfig on args as if they were the command line.
+func parseArgs(t *testing.T, args ...string) config {
+	t.Helper()
+	oldArgs, oldFlags := os.Args, flag.CommandLine
+	t.Cleanup(func() { os.Args, flag.CommandLine = oldArgs, oldFlags })
+	os.Args = append([]string{"cassandra-test"}, args...)
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	return parseConfig()
+}
+
+func TestClusterFlagsOverrideFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cluster.json")
+	if err := os.WriteFile(path, []byte(`{
+		"hosts": ["cass1", "cass2"],
+		"port": 9142,
+		"keyspace": "from_file",
+		"consistency": "LOCAL_QUORUM",
+		"username": "file_user",
+		"tls": {"ca_file": "/etc/cassandra/ca.pem"}
+	}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := parseArgs(t, "-self-test", "-cluster-config", path, "-keyspace", "from_flag", "-hosts", "flag1,flag2", "-tls-server-name", "cassandra.example.com")
+
+	c := cfg.Cluster
+	if want := []string{"flag1", "flag2"}; !reflect.DeepEqual(c.Hosts, want) {
+		t.Errorf("hosts = %v, want %v from -hosts", c.Hosts, want)
+	}
+	if c.Keyspace != "from_flag" {
+		t.Errorf("keyspace = %q, want from_flag from -keyspace", c.Keyspace)
+	}
+	// Fields no flag was given for keep the file's values.
+	if c.Port != 9142 || c.Consistency != "LOCAL_QUORUM" || c.Username != "file_user" {
+		t.Errorf("port, consistency, username = %d, %q, %q, want the file's 9142, LOCAL_QUORUM, file_user", c.Port, c.Consistency, c.Username)
+	}
+	// A TLS flag adds to the file's TLS settings rather than replacing them.
+	if c.TLS == nil || c.TLS.CAFile != "/etc/cassandra/ca.pem" || c.TLS.ServerName != "cassandra.example.com" {
+		t.Errorf("TLS = %+v, want the file's CA file and the -tls-server-name", c.TLS)
+	}
+}
+
+func TestClusterFlagsWithoutFile(t *testing.T) {
+	cfg := parseArgs(t, "-self-test", "-port", "19042", "-tls-ca", "ca.pem")
+	want := bench.DefaultClusterSettings()
+	want.Port = 19042
+	want.TLS = &bench.TLSSettings{CAFile: "ca.pem"}
+	if !reflect.DeepEqual(cfg.Cluster, want) {
+		t.Errorf("cluster settings = %+v, want the defaults with -port and -tls-ca applied: %+v", cfg.Cluster, want)
+	}
+}
@@ -0,0 +1,723 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/gocql/gocql"
+	"golang.org/x/term"
+
+	"cassandra-test/bench"
+)
+
+const usage = "Usage: go run . [flags] <concurrency_level> <number_of_queries> <keys_file_path>\n" +
+	"Counts accept k and m suffixes, e.g. 1m for 1000000."
+
+// config holds the settings for a benchmark run, gathered from flags and the
+// positional arguments: the benchmark's own and those only the CLI acts on.
+type config struct {
+	bench.Config
+
+	Output     string
+	TraceFile  string
+	GOMAXPROCS int
+
+	MinHitRatePct float64
+	Strict        bool
+
+	MaxRuntime time.Duration
+
+	LeakCheck bool
+
+	ConnectOnly bool
+
+	CoordinatorListen string
+	CoordinatorExpect int
+	CoordinatorURL    string
+
+	HgrmFile string
+
+	SelfTest          bool
+	SelfTestLatency   time.Duration
+	SelfTestJitter    time.Duration
+	SelfTestErrorRate float64
+	SelfTestMissRate  float64
+
+	SaveBundle   string
+	SeedFromFile string
+
+	TUI bool
+
+	TimelineFile string
+
+	GOGC          int
+	MemoryLimitMB int
+
+	ExistenceCheck int
+
+	PostResultURL     string
+	PostResultFormat  string
+	PostResultTimeout time.Duration
+	PostResultRetries int
+}
+
+// parseConfig parses the command line and exits on invalid input.
+func parseConfig() config {
+	var cfg config
+	flag.BoolVar(&cfg.BestEffort, "best-effort", false, "Skip unparseable keys file entries instead of aborting")
+	flag.Var(newCountFlag(&cfg.MinKeys, 0), "min-keys", "Fail if fewer keys than this load from the keys file, e.g. a truncated file (0 disables)")
+	flag.StringVar(&cfg.KeysFormat, "keys-format", "json", "Keys file format: json (a JSON array or NDJSON) or csv")
+	csvColumns := flag.String("csv-columns", "", "CSV column mapping for -keys-format csv: FIELD or FIELD=HEADER entries naming the header column of each field, or with -no-header the field of each column in order (\"-\" skips one); fields are eqp_model, job_id, strtgy_name and bucket (default \"eqp_model,job_id,strtgy_name\")")
+	flag.BoolVar(&cfg.CSVNoHeader, "no-header", false, "The -keys-format csv file has no header row; columns are mapped by position")
+	flag.BoolVar(&bench.StrictKeyJSON, "strict-key-json", false, "Reject numeric key fields instead of coercing them to strings")
+	flag.DurationVar(&cfg.ThinkTime, "think-time", 0, "Mean idle time each worker waits between queries")
+	flag.DurationVar(&cfg.ThinkJitter, "think-jitter", 0, "Maximum random deviation applied to -think-time")
+	flag.DurationVar(&cfg.OpTimeout, "op-timeout", 0, "Client-side deadline for each query, independent of the driver timeout (0 disables)")
+	flag.Var(newCountFlag(&cfg.Retries, 0), "retries", "App-level retries of a failed query")
+	flag.Var(newCountFlag(&cfg.RunRetries, 0), "run-retries", "Reconnect and rerun the whole benchmark up to this many times when its first -run-retry-probe queries all fail")
+	flag.Var(newCountFlag(&cfg.RunRetryProbe, 10), "run-retry-probe", "Number of leading queries that must all fail for -run-retries to rerun")
+	flag.DurationVar(&cfg.RunRetryDelay, "run-retry-delay", 10*time.Second, "Pause before each -run-retries rerun")
+	flag.DurationVar(&cfg.RetryBudget, "retry-budget", 0, "Total client-side deadline shared by a query's attempts, including -retries (0 disables)")
+	flag.StringVar(&cfg.Output, "output", "text", "Summary format: text, json or markdown")
+	flag.Float64Var(&cfg.TraceSample, "cql-trace-sample", 0, "Fraction of queries (0-1) to run with server-side CQL tracing")
+	flag.StringVar(&cfg.TraceFile, "cql-trace-file", "cql_trace.log", "File that receives CQL trace events when -cql-trace-sample is set")
+	flag.Var(newCountFlag(&cfg.GOMAXPROCS, 0), "gomaxprocs", "Set runtime.GOMAXPROCS before the run (0 leaves it unchanged)")
+	flag.IntVar(&cfg.GOGC, "gogc", 0, "Set the GC percentage before the run (0 leaves GOGC unchanged, -1 disables GC); 200-400 cuts GC pauses at high QPS")
+	flag.IntVar(&cfg.MemoryLimitMB, "memory-limit-mb", 0, "Soft memory limit for the client in MiB (0 for none); bounds the heap when -gogc is raised or off")
+	flag.BoolVar(&cfg.CountOnly, "count-only", false, "Only check that each key exists (LIMIT 1, no row scan)")
+	flag.BoolVar(&cfg.NoPrepare, "no-prepare", false, "Measure unprepared execution (literal values) against a prepared reference pass")
+	flag.Float64Var(&cfg.SplitPrepare, "split-prepare", 0, "Run this fraction (0-1) of queries prepared and the rest unprepared, reporting both cohorts side by side (0 disables)")
+	flag.Var(newCountFlag(&cfg.ResultBuffer, 10000), "result-buffer", "Latency samples buffered for the collector before new ones are dropped")
+	targets := flag.String("targets", "", "Weighted keyspace.table list such as \"ks1.test_table:3,ks2.test_table:1\" (default test.test_table)")
+	flag.Float64Var(&cfg.MinHitRatePct, "min-hit-rate", 1, "Warn when the hit rate (percent) falls below this threshold")
+	flag.BoolVar(&cfg.Strict, "strict", false, "Exit with status 3 when the hit rate is below -min-hit-rate")
+	flag.Float64Var(&cfg.Rate, "rate", 0, "Target queries per second across all workers (0 is unlimited)")
+	phases := flag.String("phases", "", "Passes to run in order, such as \"write:100000,read:100000\"; a read after a write reads only the keys just written. Takes <concurrency_level> [keys_file_path], generating keys when no file is given")
+	rateSchedule := flag.String("rate-schedule", "", "Step-load schedule of RATE:DURATION steps such as \"1000:30s,2000:30s,4000:30s\", reported per step")
+	flag.Float64Var(&cfg.SLAP99Ms, "sla-p99-ms", 0, "Search for the highest rate whose p99 latency stays under this many milliseconds")
+	flag.Float64Var(&cfg.MaxRate, "max-rate", 10000, "Upper bound for the -sla-p99-ms rate search")
+	flag.Var(newCountFlag(&cfg.SLAProbes, 10), "sla-probes", "Number of probe phases in the -sla-p99-ms rate search")
+	flag.DurationVar(&cfg.ProbeDuration, "probe-duration", 10*time.Second, "Length of each -sla-p99-ms probe phase")
+	dateRange := flag.String("date-range", "", "Spread queries over day buckets FROM:TO (YYYY-MM-DD:YYYY-MM-DD)")
+	flag.StringVar(&cfg.BucketColumn, "bucket-column", "", "Date column bound to each key's day bucket: the key's own \"bucket\" field, or one picked from -date-range (required with -date-range)")
+	flag.Float64Var(&cfg.BucketDecay, "bucket-decay", 1, "Query density of each day bucket relative to the next newer one (1 is uniform)")
+	flag.DurationVar(&cfg.MaxRuntime, "max-runtime", 0, "Hard wall-clock cap; the run is cancelled and exits with status 4 once exceeded (0 disables)")
+	flag.BoolVar(&cfg.LeakCheck, "leak-check", false, "Warn if goroutines remain after the session is closed")
+	flag.BoolVar(&cfg.Replay, "replay", false, "Treat the keys file as a query log with \"ts\" fields and replay it with the recorded timing")
+	flag.Float64Var(&cfg.ReplaySpeed, "speed", 1, "Replay speed factor for -replay (2 replays twice as fast)")
+	sweepConsistency := flag.String("sweep-consistency", "", "Run the workload once per consistency level, such as \"one,local_quorum,quorum,all\", and compare QPS and p99 across them")
+	consistencyMix := flag.String("consistency-mix", "", "Per-query consistency distribution such as \"LOCAL_ONE:0.8,QUORUM:0.2\" (overrides -consistency)")
+	flag.BoolVar(&cfg.PhaseTiming, "phase-timing", false, "Time the bind, execute and scan phases of each query separately (adds overhead)")
+	flag.Var(newCountFlag(&cfg.Warmup, 0), "warmup", "Queries to run before the measured pass, with results discarded")
+	flag.BoolVar(&cfg.PoolWait, "pool-wait", false, "Report how long queries waited in the client before executing, versus executing")
+	flag.BoolVar(&cfg.ReportHosts, "report-hosts", false, "Report attempts, errors and latency per coordinator and flag hosts with an outsized error rate")
+	flag.BoolVar(&cfg.ReportDC, "report-dc", false, "Report the datacenter of each query's coordinator (implied by -local-dc)")
+	flag.BoolVar(&cfg.FastPath, "fast-path", false, "Read at LOCAL_ONE first and fall back to QUORUM when that fails")
+	flag.BoolVar(&cfg.FallbackOnMiss, "fallback-on-miss", false, "With -fast-path, also fall back to QUORUM when LOCAL_ONE finds no row")
+	flag.Float64Var(&cfg.SlowQueryMs, "slow-query-ms", 0, "Log queries slower than this many milliseconds with their key, at most one line per second (0 disables)")
+	flag.BoolVar(&cfg.MeasureBytes, "measure-bytes", false, "Estimate bytes read per query from the scanned values, and bytes written from the bound values of writes (adds per-row work)")
+	flag.BoolVar(&cfg.TUI, "tui", false, "Show a live dashboard of QPS, p99, error rate and in-flight queries instead of progress lines (needs a terminal)")
+	flag.DurationVar(&cfg.ProgressInterval, "progress-interval", 0, "Print a progress line this often during each measured pass (0 disables)")
+	flag.StringVar(&cfg.WebhookURL, "webhook-url", "", "POST the partial result JSON here at every -progress-interval")
+	flag.StringVar(&cfg.PostResultURL, "post-result-url", "", "POST the final key metrics as one flat row here after the run, e.g. a spreadsheet webhook")
+	flag.StringVar(&cfg.PostResultFormat, "post-result-format", "json", "Encoding of the -post-result-url row: json or form")
+	flag.DurationVar(&cfg.PostResultTimeout, "post-result-timeout", 10*time.Second, "Timeout of each -post-result-url attempt")
+	flag.Var(newCountFlag(&cfg.PostResultRetries, 2), "post-result-retries", "Retries of a failed -post-result-url POST")
+	flag.BoolVar(&cfg.StreamKeys, "stream-keys", false, "Decode the keys file in the background while the workers query, each key once, instead of loading it first")
+	flag.Var(newCountFlag(&cfg.ChunkSize, 0), "chunk-size", "Stream the keys file in chunks of this many keys, querying each key once per chunk, to bound memory (0 loads all keys)")
+	flag.BoolVar(&cfg.Prime, "prime", false, "Read every key once, concurrently and unmeasured, before the measured run")
+	flag.BoolVar(&cfg.ColdStart, "cold-start", false, "Recreate the session after -warmup so the measured pass starts on cold connections")
+	flag.Float64Var(&cfg.WriteMix, "write-mix", 0, "Fraction of queries sent as non-idempotent counter increments to -counter-table, read back after the run to count double-applied writes")
+	flag.StringVar(&cfg.CounterTable, "counter-table", "write_check", "Counter table for -write-mix: (run_id text, key text, applied counter, PRIMARY KEY ((run_id, key)))")
+	flag.BoolVar(&cfg.RetryNonIdempotent, "retry-non-idempotent", false, "Let -retries resend -write-mix writes too, which can apply them twice")
+	flag.BoolVar(&cfg.NullKeyMiss, "null-key-miss", false, "Count rows that come back with a NULL eqp_model as misses instead of hits")
+	flag.StringVar(&cfg.MetadataColumn, "metadata-column", "", "Read WRITETIME and TTL of this regular column instead of the row and report their distribution")
+	flag.DurationVar(&cfg.ExpiryWindow, "expiry-window", 24*time.Hour, "With -metadata-column, count cells whose TTL runs out within this window as near expiry")
+	flag.BoolVar(&cfg.PartitionOnly, "partition-only", false, "Query by partition key (eqp_model) only and scan every row of the partition")
+	flag.Var(newCountFlag(&cfg.InList, 0), "in-list", "Group keys sharing eqp_model and job_id and read up to this many strtgy_name values per query with IN")
+	flag.Var(newCountFlag(&cfg.Limit, 0), "limit", "With -partition-only, bind a LIMIT of this many clustering rows per query (0 scans the whole partition)")
+	flag.StringVar(&cfg.OrderBy, "order-by", "", "With -partition-only, clustering order of the range read, e.g. \"job_id DESC\"")
+	flag.BoolVar(&cfg.SelfTest, "self-test", false, "Run the full pipeline against an in-memory fake instead of Cassandra (positional arguments optional)")
+	flag.DurationVar(&cfg.SelfTestLatency, "self-test-latency", 2*time.Millisecond, "Latency of each -self-test query")
+	flag.DurationVar(&cfg.SelfTestJitter, "self-test-jitter", time.Millisecond, "Uniform jitter around -self-test-latency")
+	flag.Float64Var(&cfg.SelfTestErrorRate, "self-test-error-rate", 0.01, "Fraction of -self-test queries that fail, half as server read timeouts")
+	flag.Float64Var(&cfg.SelfTestMissRate, "self-test-miss-rate", 0.05, "Fraction of -self-test queries that find no row")
+	flag.BoolVar(&cfg.MapScan, "map-scan", false, "Scan rows with MapScan into a map keyed by column name instead of by position, and report the columns discovered")
+	flag.BoolVar(&cfg.PrintSampleQuery, "print-sample-query", false, "Debugging aid: print the first query's CQL with the first key's values inlined, for cqlsh, before the run")
+	flag.Var(newCountFlag(&cfg.MaxPartitions, 0), "max-partitions", "Query only the keys in the first N distinct partitions (eqp_model, plus bucket when keys carry one) of the keys file, e.g. to fit the row cache (0 for all)")
+	flag.Var(newCountFlag(&cfg.ExistenceCheck, 0), "existence-check", "Read this many random keys with LIMIT 1 at low concurrency, report the fraction that exists with a 95% confidence interval and exit (takes only <keys_file_path>)")
+	flag.BoolVar(&cfg.ConnectOnly, "connect-only", false, "Only connect, query system.local, print cluster details and exit (no positional arguments needed)")
+	flag.Var(newCountFlag(&cfg.Repeat, 1), "repeat", "Run the measured pass this many times and report the medians")
+	flag.BoolVar(&cfg.FixedOrder, "fixed-order", false, "Keep the key order fixed across -repeat iterations instead of reshuffling")
+	flag.Int64Var(&cfg.Seed, "seed", 0, "Seed for the -repeat reshuffle and each worker's random choices (0 picks one from the clock)")
+	flag.StringVar(&cfg.SaveBundle, "save-bundle", "", "Write the run's flags, arguments, resolved -seed and keys file hash to this JSON file")
+	flag.StringVar(&cfg.SeedFromFile, "seed-from-file", "", "Rerun a -save-bundle file; flags and arguments given on the command line still win")
+	flag.BoolVar(&cfg.FailFast, "fail-fast", false, "Stop the run on the first query error, printing the key and error that triggered it")
+	flag.Var(newCountFlag(&cfg.SampleErrors, 0), "sample-errors-with-keys", "Keep up to this many failing keys with their errors and print them in the summary")
+	flag.BoolVar(&cfg.ValidateKeys, "validate-json-schema", false, "Check every keys file record's field names against the bind fields before the run")
+	flag.BoolVar(&fsyncOutputs, "fsync", false, "Fsync the output files (-hgrm-file, -timeline-file, -cql-trace-file, -save-bundle) and a redirected stdout before exiting")
+	flag.StringVar(&cfg.Tag, "tag", "", "Label recorded in the result, e.g. the tc/netem condition the run was made under (\"loss=1%\")")
+	flag.StringVar(&cfg.TimelineFile, "timeline-file", "", "Write per-second completions, errors and in-flight queries of the measured passes to this CSV file")
+	flag.StringVar(&cfg.HgrmFile, "hgrm-file", "", "Write the measured latencies as an HdrHistogram .hgrm percentile file (milliseconds)")
+	keyTypes := flag.String("key-types", "", "Comma-separated CQL types for eqp_model, job_id, strtgy_name in bind order, e.g. text,bigint,text")
+	flag.StringVar(&cfg.CoordinatorListen, "coordinator-listen", "", "Run as a coordinator on this address (e.g. :8080), merging results posted by workers")
+	flag.Var(newCountFlag(&cfg.CoordinatorExpect, 1), "coordinator-expect", "Number of worker results the coordinator waits for")
+	flag.StringVar(&cfg.CoordinatorURL, "coordinator-url", "", "POST the final result to a coordinator, e.g. http://host:8080/results")
+	clusterConfig := flag.String("cluster-config", "", "JSON file with hosts, port, keyspace, consistency, auth and TLS settings")
+	conn := bench.DefaultClusterSettings()
+	hosts := flag.String("hosts", strings.Join(conn.Hosts, ","), "Comma-separated contact points")
+	flag.IntVar(&conn.Port, "port", conn.Port, "CQL native transport port")
+	flag.StringVar(&conn.Keyspace, "keyspace", conn.Keyspace, "Keyspace for the session")
+	flag.StringVar(&conn.Consistency, "consistency", conn.Consistency, "Consistency level, e.g. ONE, LOCAL_QUORUM, QUORUM")
+	flag.StringVar(&conn.LocalDC, "local-dc", conn.LocalDC, "Route queries to this datacenter (DC-aware, token-aware) and report cross-DC coordinators")
+	flag.StringVar(&conn.PinHost, "pin-host", conn.PinHost, "Send every query to this one node as coordinator (diagnostics only; disables load balancing)")
+	flag.Var(newCountFlag(&conn.NumRetries, conn.NumRetries), "num-retries", "Let gocql retry a failed read on the next host up to this many times")
+	flag.StringVar(&conn.CoalesceWait, "coalesce-wait", conn.CoalesceWait, "How long gocql batches a connection's writes into one syscall, e.g. 200us or 0 to turn it off (default gocql's 200us); more trades latency for throughput")
+	flag.StringVar(&conn.SocksProxy, "socks-proxy", conn.SocksProxy, "Connect through this SOCKS5 proxy, as host:port or socks5://[user:password@]host:port")
+	flag.StringVar(&conn.Username, "username", conn.Username, "Username for password authentication (none by default)")
+	flag.StringVar(&conn.Password, "password", conn.Password, "Password for password authentication (visible in process listings; prefer -password-file or the prompt)")
+	passwordFile := flag.String("password-file", "", "Read the password from this file instead of -password")
+	flag.BoolVar(&conn.NoAuth, "no-auth", false, "Connect without authentication even if credentials are configured")
+	authenticators := flag.String("authenticators", "", "Comma-separated server authenticator classes to accept, for custom SASL setups")
+	var tlsFlags bench.TLSSettings
+	flag.StringVar(&tlsFlags.CAFile, "tls-ca", "", "CA certificate file; enables TLS")
+	flag.StringVar(&tlsFlags.CertFile, "tls-cert", "", "Client certificate file; enables TLS")
+	flag.StringVar(&tlsFlags.KeyFile, "tls-key", "", "Client key file; enables TLS")
+	flag.StringVar(&tlsFlags.ServerName, "tls-server-name", "", "Server name to verify; enables TLS")
+	flag.BoolVar(&tlsFlags.InsecureSkipVerify, "tls-skip-verify", false, "Skip server certificate verification; enables TLS")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var bundle *runBundle
+	if cfg.SeedFromFile != "" {
+		var err error
+		bundle, err = loadBundle(cfg.SeedFromFile)
+		if err != nil {
+			log.Fatalf("Failed to load -seed-from-file: %v", err)
+		}
+		if err := bundle.apply(); err != nil {
+			log.Fatalf("Invalid -seed-from-file: %v", err)
+		}
+	}
+
+	var err error
+	if *phases != "" {
+		cfg.Phases, err = bench.ParsePhases(*phases)
+		if err != nil {
+			log.Fatalf("Invalid -phases: %v", err)
+		}
+	}
+
+	// Get the concurrency level from the command-line argument. A
+	// -connect-only probe or a coordinator runs no workload and needs none
+	// of them; a -self-test falls back to a small synthetic workload.
+	// -phases sets the query counts itself.
+	if cfg.ConnectOnly || cfg.CoordinatorListen != "" {
+		cfg.Concurrency = 1
+	} else if cfg.ExistenceCheck > 0 && flag.NArg() == 1 {
+		cfg.Concurrency = bench.ExistenceConcurrency
+		cfg.NumQueries = cfg.ExistenceCheck
+		cfg.KeysFilePath = flag.Arg(0)
+	} else if len(cfg.Phases) > 0 && !cfg.SelfTest {
+		if flag.NArg() < 1 || flag.NArg() > 2 {
+			log.Fatalf("Usage: go run . -phases SPEC [flags] <concurrency_level> [keys_file_path]")
+		}
+		cfg.Concurrency, err = bench.ParseCount(flag.Arg(0))
+		if err != nil || cfg.Concurrency <= 0 {
+			log.Fatalf("Invalid concurrency level %q. Please provide a positive integer such as 64 or 1k.", flag.Arg(0))
+		}
+		cfg.KeysFilePath = flag.Arg(1)
+		if cfg.KeysFilePath == "" && cfg.Phases[0].Op != "write" {
+			log.Fatalf("-phases without a keys file generates its keys and must start with a write phase.")
+		}
+	} else if cfg.SelfTest && flag.NArg() == 0 {
+		cfg.Concurrency = selfTestConcurrency
+		cfg.NumQueries = selfTestQueries
+	} else {
+		// A -self-test generates keys when no keys file is given.
+		if flag.NArg() < 3 && !(cfg.SelfTest && flag.NArg() == 2) {
+			log.Fatalf(usage)
+		}
+		cfg.Concurrency, err = bench.ParseCount(flag.Arg(0))
+		if err != nil || cfg.Concurrency <= 0 {
+			log.Fatalf("Invalid concurrency level %q. Please provide a positive integer such as 64 or 1k.", flag.Arg(0))
+		}
+		cfg.NumQueries, err = bench.ParseCount(flag.Arg(1))
+		if err != nil || cfg.NumQueries <= 0 {
+			log.Fatalf("Invalid number of queries %q. Please provide a positive integer such as 100000 or 1m.", flag.Arg(1))
+		}
+		cfg.KeysFilePath = flag.Arg(2)
+	}
+	if bundle != nil {
+		bundle.checkKeys(cfg.KeysFilePath)
+	}
+
+	if cfg.ThinkTime < 0 || cfg.ThinkJitter < 0 {
+		log.Fatalf("Invalid think time. -think-time and -think-jitter must not be negative.")
+	}
+	if cfg.SlowQueryMs < 0 {
+		log.Fatalf("Invalid slow query threshold. -slow-query-ms must not be negative.")
+	}
+	if cfg.Retries < 0 {
+		log.Fatalf("Invalid retries. -retries must not be negative.")
+	}
+	if cfg.RunRetries < 0 || cfg.RunRetryProbe <= 0 || cfg.RunRetryDelay < 0 {
+		log.Fatalf("Invalid run retries. -run-retries and -run-retry-delay must not be negative and -run-retry-probe must be positive.")
+	}
+	if cfg.RetryBudget < 0 {
+		log.Fatalf("Invalid retry budget. -retry-budget must not be negative.")
+	}
+	if cfg.OpTimeout < 0 {
+		log.Fatalf("Invalid operation timeout. -op-timeout must not be negative.")
+	}
+	if cfg.Output != "text" && cfg.Output != "json" && cfg.Output != "markdown" {
+		log.Fatalf("Invalid output format %q. Use text, json or markdown.", cfg.Output)
+	}
+	if cfg.TraceSample < 0 || cfg.TraceSample > 1 {
+		log.Fatalf("Invalid trace sample. -cql-trace-sample must be between 0 and 1.")
+	}
+	if cfg.ResultBuffer <= 0 {
+		log.Fatalf("Invalid result buffer. -result-buffer must be positive.")
+	}
+	if *targets != "" {
+		cfg.Targets, err = bench.ParseTargets(*targets)
+		if err != nil {
+			log.Fatalf("Invalid -targets: %v", err)
+		}
+	}
+	if cfg.MinHitRatePct < 0 || cfg.MinHitRatePct > 100 {
+		log.Fatalf("Invalid hit rate threshold. -min-hit-rate must be between 0 and 100.")
+	}
+	cfg.Cluster = conn
+	if *clusterConfig != "" {
+		cfg.Cluster, err = bench.LoadClusterSettings(*clusterConfig)
+		if err != nil {
+			log.Fatalf("Failed to load cluster config: %v", err)
+		}
+	}
+	// Flags given explicitly win over the cluster config file.
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "hosts":
+			cfg.Cluster.Hosts = bench.SplitList(*hosts)
+		case "port":
+			cfg.Cluster.Port = conn.Port
+		case "keyspace":
+			cfg.Cluster.Keyspace = conn.Keyspace
+		case "consistency":
+			cfg.Cluster.Consistency = conn.Consistency
+		case "local-dc":
+			cfg.Cluster.LocalDC = conn.LocalDC
+		case "pin-host":
+			cfg.Cluster.PinHost = conn.PinHost
+		case "num-retries":
+			cfg.Cluster.NumRetries = conn.NumRetries
+		case "socks-proxy":
+			cfg.Cluster.SocksProxy = conn.SocksProxy
+		case "coalesce-wait":
+			cfg.Cluster.CoalesceWait = conn.CoalesceWait
+		case "username":
+			cfg.Cluster.Username = conn.Username
+		case "password":
+			cfg.Cluster.Password = conn.Password
+		case "no-auth":
+			cfg.Cluster.NoAuth = conn.NoAuth
+		case "authenticators":
+			cfg.Cluster.Authenticators = bench.SplitList(*authenticators)
+		case "tls-ca", "tls-cert", "tls-key", "tls-server-name", "tls-skip-verify":
+			if cfg.Cluster.TLS == nil {
+				cfg.Cluster.TLS = &bench.TLSSettings{}
+			}
+			switch f.Name {
+			case "tls-ca":
+				cfg.Cluster.TLS.CAFile = tlsFlags.CAFile
+			case "tls-cert":
+				cfg.Cluster.TLS.CertFile = tlsFlags.CertFile
+			case "tls-key":
+				cfg.Cluster.TLS.KeyFile = tlsFlags.KeyFile
+			case "tls-server-name":
+				cfg.Cluster.TLS.ServerName = tlsFlags.ServerName
+			case "tls-skip-verify":
+				cfg.Cluster.TLS.InsecureSkipVerify = tlsFlags.InsecureSkipVerify
+			}
+		}
+	})
+	if cfg.Cluster.LocalDC != "" {
+		cfg.ReportDC = true
+	}
+	if cfg.Cluster.PinHost != "" {
+		log.Printf("WARNING: -pin-host sends every query to %s, bypassing load balancing; use it for diagnostics only.", cfg.Cluster.PinHost)
+	}
+	if cfg.Cluster.NumRetries < 0 {
+		log.Fatalf("Invalid retry count. -num-retries must not be negative.")
+	}
+	if cfg.Cluster.CoalesceWait != "" {
+		if wait, err := time.ParseDuration(cfg.Cluster.CoalesceWait); err != nil || wait < 0 {
+			log.Fatalf("Invalid coalesce wait %q. -coalesce-wait must be a non-negative duration such as 200us.", cfg.Cluster.CoalesceWait)
+		}
+	}
+	if cfg.Cluster.SocksProxy != "" {
+		if _, _, err := bench.ParseSocksProxy(cfg.Cluster.SocksProxy); err != nil {
+			log.Fatalf("Invalid -socks-proxy: %v", err)
+		}
+	}
+	if *passwordFile != "" && cfg.Cluster.Password != "" {
+		log.Fatalf("-password-file cannot be combined with a password from -password or -cluster-config.")
+	}
+	// Without -password-file a missing password is prompted for when stdin
+	// is a terminal.
+	if err := readPassword(&cfg.Cluster, *passwordFile); err != nil {
+		log.Fatalf("Failed to read password: %v", err)
+	}
+	if _, err := gocql.ParseConsistencyWrapper(strings.ToUpper(cfg.Cluster.Consistency)); err != nil {
+		log.Fatalf("Invalid consistency level %q.", cfg.Cluster.Consistency)
+	}
+	if cfg.Rate < 0 {
+		log.Fatalf("Invalid rate. -rate must not be negative.")
+	}
+	if cfg.SLAP99Ms < 0 {
+		log.Fatalf("Invalid SLA target. -sla-p99-ms must not be negative.")
+	}
+	if cfg.SLAP99Ms > 0 && (cfg.MaxRate <= 0 || cfg.SLAProbes <= 0 || cfg.ProbeDuration <= 0) {
+		log.Fatalf("Invalid SLA search. -max-rate, -sla-probes and -probe-duration must be positive.")
+	}
+	if *rateSchedule != "" {
+		cfg.RateSchedule, err = bench.ParseRateSchedule(*rateSchedule)
+		if err != nil {
+			log.Fatalf("Invalid -rate-schedule: %v", err)
+		}
+	}
+	if len(cfg.Phases) > 0 {
+		cfg.NumQueries = 0
+		for _, p := range cfg.Phases {
+			cfg.NumQueries += p.Queries
+		}
+	}
+	if *dateRange != "" {
+		cfg.Buckets, err = bench.ParseDateRange(*dateRange)
+		if err != nil {
+			log.Fatalf("Invalid -date-range: %v", err)
+		}
+		if cfg.BucketColumn == "" {
+			log.Fatalf("-date-range requires -bucket-column.")
+		}
+	}
+	if cfg.BucketColumn != "" && len(cfg.Buckets) == 0 && len(cfg.Phases) > 0 && cfg.KeysFilePath == "" {
+		log.Fatalf("-phases without a keys file generates keys without buckets; give -date-range with -bucket-column.")
+	}
+	if cfg.BucketDecay <= 0 || cfg.BucketDecay > 1 {
+		log.Fatalf("Invalid bucket decay. -bucket-decay must be in (0, 1].")
+	}
+	if cfg.MaxRuntime < 0 {
+		log.Fatalf("Invalid max runtime. -max-runtime must not be negative.")
+	}
+	if cfg.ReplaySpeed <= 0 {
+		log.Fatalf("Invalid replay speed. -speed must be positive.")
+	}
+	if *consistencyMix != "" {
+		cfg.ConsistencyMix, err = bench.ParseConsistencyMix(*consistencyMix)
+		if err != nil {
+			log.Fatalf("Invalid -consistency-mix: %v", err)
+		}
+	}
+	if *sweepConsistency != "" {
+		cfg.SweepConsistency, err = bench.ParseConsistencySweep(*sweepConsistency)
+		if err != nil {
+			log.Fatalf("Invalid -sweep-consistency: %v", err)
+		}
+	}
+	if cfg.FallbackOnMiss && !cfg.FastPath {
+		log.Fatalf("-fallback-on-miss requires -fast-path.")
+	}
+	if cfg.SelfTest {
+		if cfg.SelfTestErrorRate < 0 || cfg.SelfTestErrorRate > 1 || cfg.SelfTestMissRate < 0 || cfg.SelfTestMissRate > 1 {
+			log.Fatalf("Invalid -self-test rates. -self-test-error-rate and -self-test-miss-rate must be between 0 and 1.")
+		}
+		if cfg.SelfTestLatency < 0 || cfg.SelfTestJitter < 0 || cfg.SelfTestJitter > cfg.SelfTestLatency {
+			log.Fatalf("Invalid -self-test latency. -self-test-jitter must be between 0 and -self-test-latency.")
+		}
+	}
+	if cfg.ChunkSize < 0 {
+		log.Fatalf("Invalid chunk size. -chunk-size must not be negative.")
+	}
+	if cfg.SplitPrepare < 0 || cfg.SplitPrepare >= 1 {
+		log.Fatalf("Invalid prepared split. -split-prepare must be in [0, 1).")
+	}
+	if cfg.MinKeys < 0 {
+		log.Fatalf("Invalid minimum key count. -min-keys must not be negative.")
+	}
+	if cfg.ProgressInterval < 0 {
+		log.Fatalf("Invalid progress interval. -progress-interval must not be negative.")
+	}
+	if cfg.TUI {
+		if !stdoutIsTerminal() {
+			log.Printf("stdout is not a terminal; -tui falls back to progress lines.")
+			cfg.TUI = false
+		}
+		if cfg.ProgressInterval == 0 {
+			cfg.ProgressInterval = time.Second
+		}
+	}
+	if cfg.TUI {
+		cfg.NewDisplay = func() (bench.Display, error) {
+			screen, err := tcell.NewScreen()
+			if err != nil {
+				return nil, err
+			}
+			return newDashboard(screen, cfg.Concurrency)
+		}
+	}
+	if cfg.PostResultFormat != "json" && cfg.PostResultFormat != "form" {
+		log.Fatalf("Invalid post result format %q. -post-result-format must be json or form.", cfg.PostResultFormat)
+	}
+	if cfg.PostResultTimeout <= 0 || cfg.PostResultRetries < 0 {
+		log.Fatalf("Invalid result post. -post-result-timeout must be positive and -post-result-retries not negative.")
+	}
+	if cfg.WebhookURL != "" && cfg.ProgressInterval == 0 {
+		log.Fatalf("-webhook-url requires -progress-interval.")
+	}
+	if cfg.ExpiryWindow < 0 {
+		log.Fatalf("Invalid expiry window. -expiry-window must not be negative.")
+	}
+	if cfg.WriteMix < 0 || cfg.WriteMix > 1 {
+		log.Fatalf("Invalid write mix. -write-mix must be between 0 and 1.")
+	}
+	switch cfg.KeysFormat {
+	case "json":
+		if *csvColumns != "" || cfg.CSVNoHeader {
+			log.Fatalf("-csv-columns and -no-header require -keys-format csv.")
+		}
+	case "csv":
+		cfg.CSVColumns, err = bench.ParseCSVColumns(*csvColumns, cfg.CSVNoHeader)
+		if err != nil {
+			log.Fatalf("Invalid -csv-columns: %v", err)
+		}
+	default:
+		log.Fatalf("Invalid keys format %q. -keys-format must be json or csv.", cfg.KeysFormat)
+	}
+	if cfg.MaxPartitions < 0 {
+		log.Fatalf("Invalid max partitions. -max-partitions must not be negative.")
+	}
+	if cfg.ExistenceCheck < 0 {
+		log.Fatalf("Invalid existence check. -existence-check must not be negative.")
+	}
+	if cfg.InList < 0 {
+		log.Fatalf("Invalid IN list size. -in-list must not be negative.")
+	}
+	if cfg.Limit < 0 {
+		log.Fatalf("Invalid limit. -limit must not be negative.")
+	}
+	if (cfg.Limit > 0 || cfg.OrderBy != "") && (!cfg.PartitionOnly || cfg.CountOnly) {
+		log.Fatalf("-limit and -order-by read a clustering range and require -partition-only without -count-only.")
+	}
+	if cfg.Warmup < 0 {
+		log.Fatalf("Invalid warm-up. -warmup must not be negative.")
+	}
+	if cfg.Repeat <= 0 {
+		log.Fatalf("Invalid repeat count. -repeat must be positive.")
+	}
+	if err := checkExclusions(cfg); err != nil {
+		log.Fatalf("%v", err)
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	cfg.KeyTypes, err = bench.ParseKeyTypes(*keyTypes)
+	if err != nil {
+		log.Fatalf("Invalid -key-types: %v", err)
+	}
+	if cfg.CoordinatorListen != "" && cfg.CoordinatorExpect <= 0 {
+		log.Fatalf("Invalid -coordinator-expect. Expect at least one result.")
+	}
+	if cfg.GOMAXPROCS < 0 {
+		log.Fatalf("Invalid GOMAXPROCS. -gomaxprocs must not be negative.")
+	}
+	if cfg.GOGC < -1 {
+		log.Fatalf("Invalid GC percentage. -gogc must be -1 (off), 0 (unchanged) or positive.")
+	}
+	if cfg.MemoryLimitMB < 0 {
+		log.Fatalf("Invalid memory limit. -memory-limit-mb must not be negative.")
+	}
+	if cfg.GOGC == -1 && cfg.MemoryLimitMB == 0 {
+		log.Printf("WARNING: -gogc -1 without -memory-limit-mb never collects; the client heap grows for the whole run.")
+	}
+	cfg.Timeline = cfg.TimelineFile != ""
+	cfg.Out = os.Stdout
+	cfg.Log = log.Default()
+	return cfg
+}
+
+// printConfig echoes the effective settings so a run's output records how it
+// was produced.
+func (cfg config) printConfig() {
+	fmt.Println("Configuration:")
+	if cfg.Tag != "" {
+		fmt.Printf("  tag:             %s\n", cfg.Tag)
+	}
+	fmt.Printf("  hosts:           %s (port %d)\n", strings.Join(cfg.Cluster.Hosts, ","), cfg.Cluster.Port)
+	fmt.Printf("  keyspace:        %s\n", cfg.Cluster.Keyspace)
+	fmt.Printf("  consistency:     %s\n", strings.ToUpper(cfg.Cluster.Consistency))
+	if cfg.Cluster.LocalDC != "" {
+		fmt.Printf("  local dc:        %s\n", cfg.Cluster.LocalDC)
+	}
+	if cfg.Cluster.PinHost != "" {
+		fmt.Printf("  pinned host:     %s\n", cfg.Cluster.PinHost)
+	}
+	if cfg.Cluster.NumRetries > 0 {
+		fmt.Printf("  driver retries:  %d (next host)\n", cfg.Cluster.NumRetries)
+	}
+	coalesce := cfg.Cluster.CoalesceWait
+	if coalesce == "" {
+		coalesce = "200µs (gocql default)"
+	}
+	fmt.Printf("  coalesce wait:   %s\n", coalesce)
+	if cfg.Cluster.SocksProxy != "" {
+		// Only the address: the spec may carry the proxy password.
+		addr, _, _ := bench.ParseSocksProxy(cfg.Cluster.SocksProxy)
+		fmt.Printf("  socks proxy:     %s\n", addr)
+	}
+	for _, c := range cfg.ConsistencyMix {
+		fmt.Printf("  consistency mix: %s %.2f%%\n", c.Level, c.Weight*100)
+	}
+	if len(cfg.SweepConsistency) > 0 {
+		fmt.Printf("  sweep:           %v\n", cfg.SweepConsistency)
+	}
+	switch {
+	case cfg.Cluster.NoAuth || (cfg.Cluster.Username == "" && cfg.Cluster.Password == ""):
+		fmt.Println("  auth:            none")
+	case len(cfg.Cluster.Authenticators) > 0:
+		fmt.Printf("  auth:            password as %s (authenticators %s)\n", cfg.Cluster.Username, strings.Join(cfg.Cluster.Authenticators, ","))
+	default:
+		fmt.Printf("  auth:            password as %s\n", cfg.Cluster.Username)
+	}
+	fmt.Printf("  tls:             %t\n", cfg.Cluster.TLS != nil)
+	fmt.Printf("  concurrency:     %d\n", cfg.Concurrency)
+	fmt.Printf("  queries:         %d\n", cfg.NumQueries)
+	fmt.Printf("  keys file:       %s\n", cfg.KeysFilePath)
+	if cfg.KeysFormat == "csv" {
+		var mapping []string
+		for _, c := range cfg.CSVColumns {
+			if c.Header != "" && c.Header != c.Field {
+				mapping = append(mapping, c.Field+"="+c.Header)
+			} else {
+				mapping = append(mapping, c.Field)
+			}
+		}
+		fmt.Printf("  keys format:     csv (%s, header %t)\n", strings.Join(mapping, ","), !cfg.CSVNoHeader)
+	}
+	if cfg.MaxPartitions > 0 {
+		fmt.Printf("  max partitions:  %d\n", cfg.MaxPartitions)
+	}
+	if cfg.ExistenceCheck > 0 {
+		fmt.Printf("  existence check: %d sampled keys\n", cfg.ExistenceCheck)
+	}
+	if cfg.MinKeys > 0 {
+		fmt.Printf("  min keys:        %d\n", cfg.MinKeys)
+	}
+	fmt.Printf("  think time:      %v (jitter %v)\n", cfg.ThinkTime, cfg.ThinkJitter)
+	fmt.Printf("  op timeout:      %v\n", cfg.OpTimeout)
+	fmt.Printf("  retries:         %d (budget %v)\n", cfg.Retries, cfg.RetryBudget)
+	if cfg.RunRetries > 0 {
+		fmt.Printf("  run retries:     %d (probe %d queries, delay %v)\n", cfg.RunRetries, cfg.RunRetryProbe, cfg.RunRetryDelay)
+	}
+	fmt.Printf("  cql trace:       %.2f%%\n", cfg.TraceSample*100)
+	fmt.Printf("  partition only:  %t\n", cfg.PartitionOnly)
+	if cfg.InList > 0 {
+		fmt.Printf("  in list:         up to %d strtgy_name values per query\n", cfg.InList)
+	}
+	if cfg.MetadataColumn != "" {
+		fmt.Printf("  metadata:        WRITETIME/TTL of %s (expiry window %v)\n", cfg.MetadataColumn, cfg.ExpiryWindow)
+	}
+	if cfg.Limit > 0 || cfg.OrderBy != "" {
+		fmt.Printf("  range:           order by %q, limit %d\n", cfg.OrderBy, cfg.Limit)
+	}
+	fmt.Printf("  count only:      %t\n", cfg.CountOnly)
+	if cfg.MapScan {
+		fmt.Printf("  map scan:        true\n")
+	}
+	fmt.Printf("  lean point read: %t\n", cfg.LeanPointRead())
+	if cfg.WriteMix > 0 {
+		fmt.Printf("  write mix:       %.2f%% to %s (retry non-idempotent %t)\n", cfg.WriteMix*100, cfg.CounterTable, cfg.RetryNonIdempotent)
+	}
+	if cfg.NullKeyMiss {
+		fmt.Printf("  null key:        counted as miss\n")
+	}
+	if cfg.ProgressInterval > 0 {
+		fmt.Printf("  progress:        every %v (webhook %q)\n", cfg.ProgressInterval, cfg.WebhookURL)
+	}
+	if cfg.PostResultURL != "" {
+		fmt.Printf("  post result:     %s as %s (timeout %v, %d retries)\n", cfg.PostResultURL, cfg.PostResultFormat, cfg.PostResultTimeout, cfg.PostResultRetries)
+	}
+	fmt.Printf("  no prepare:      %t\n", cfg.NoPrepare)
+	if cfg.SplitPrepare > 0 {
+		fmt.Printf("  split prepare:   %.0f%% prepared\n", cfg.SplitPrepare*100)
+	}
+	fmt.Printf("  result buffer:   %d\n", cfg.ResultBuffer)
+	for _, t := range cfg.Targets {
+		fmt.Printf("  target:          %s (weight %g)\n", t.Name, t.Weight)
+	}
+	fmt.Printf("  min hit rate:    %.2f%% (strict %t)\n", cfg.MinHitRatePct, cfg.Strict)
+	fmt.Printf("  rate:            %g/s\n", cfg.Rate)
+	if cfg.SLAP99Ms > 0 {
+		fmt.Printf("  sla search:      p99 <= %gms, max rate %g/s, %d probes of %v\n", cfg.SLAP99Ms, cfg.MaxRate, cfg.SLAProbes, cfg.ProbeDuration)
+	}
+	if len(cfg.Buckets) > 0 {
+		fmt.Printf("  date buckets:    %s..%s on %s (%d days, decay %g)\n", cfg.Buckets[0], cfg.Buckets[len(cfg.Buckets)-1], cfg.BucketColumn, len(cfg.Buckets), cfg.BucketDecay)
+	}
+	for i, p := range cfg.Phases {
+		fmt.Printf("  phase %d:         %s %d\n", i+1, p.Op, p.Queries)
+	}
+	for i, s := range cfg.RateSchedule {
+		fmt.Printf("  rate step %d:     %g/s for %v\n", i+1, s.Rate, s.Duration)
+	}
+	if cfg.Repeat > 1 {
+		fmt.Printf("  repeat:          %d (fixed order %t, seed %d)\n", cfg.Repeat, cfg.FixedOrder, cfg.Seed)
+		if cfg.SeedFromFile != "" {
+			fmt.Printf("  bundle:          %s\n", cfg.SeedFromFile)
+		}
+	}
+	fmt.Printf("  warmup:          %d (prime %t, cold start %t)\n", cfg.Warmup, cfg.Prime, cfg.ColdStart)
+	fmt.Printf("  max runtime:     %v\n", cfg.MaxRuntime)
+	if cfg.Replay {
+		fmt.Printf("  replay:          speed %gx\n", cfg.ReplaySpeed)
+	}
+	if len(cfg.KeyTypes) > 0 {
+		fmt.Printf("  key types:       %s\n", strings.Join(cfg.KeyTypes, ","))
+	}
+	fmt.Printf("  GOMAXPROCS:      %d (NumCPU %d)\n", runtime.GOMAXPROCS(0), runtime.NumCPU())
+	fmt.Printf("  GC:              %s\n", gcSettings(cfg))
+}
+
+// readPassword fills in the password of s from path, or, when no password
+// was given and stdin is a terminal, by prompting for it without echo. The
+// password never appears on the command line, in logs or in the config echo.
+func readPassword(s *bench.ClusterSettings, path string) error {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		s.Password = strings.TrimRight(string(data), "\r\n")
+		return nil
+	}
+	if s.NoAuth || s.Username == "" || s.Password != "" || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Password for %s: ", s.Username)
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+	s.Password = string(password)
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// connectOnly is the -connect-only probe: it creates a session with the
+// configured connection settings, runs a trivial query and prints what the
+// coordinator reports about the cluster. It returns exitConnect on any
+// failure, so it can gate CI jobs on connectivity.
+func connectOnly(cfg config) int {
+	cluster, err := cfg.Cluster.NewCluster(1)
+	if err != nil {
+		log.Printf("Invalid cluster configuration: %v", err)
+		return exitConnect
+	}
+
+	start := time.Now()
+	session, err := cluster.CreateSession()
+	if err != nil {
+		log.Printf("Failed to connect to Cassandra: %v", err)
+		return exitConnect
+	}
+	defer session.Close()
+	connectTime := time.Since(start)
+
+	var now time.Time
+	if err := session.Query("SELECT now() FROM system.local").Scan(&now); err != nil {
+		log.Printf("Connected, but SELECT now() FROM system.local failed: %v", err)
+		return exitConnect
+	}
+
+	var clusterName, version, datacenter string
+	if err := session.Query("SELECT cluster_name, release_version, data_center FROM system.local").Scan(&clusterName, &version, &datacenter); err != nil {
+		log.Printf("Connected, but reading cluster details failed: %v", err)
+		return exitConnect
+	}
+
+	fmt.Println("Connection OK.")
+	fmt.Printf("  cluster:     %s\n", clusterName)
+	fmt.Printf("  version:     %s\n", version)
+	fmt.Printf("  datacenter:  %s\n", datacenter)
+	fmt.Printf("  connect:     %.2f ms\n", float64(connectTime)/float64(time.Millisecond))
+	return exitOK
+}
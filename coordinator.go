@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"cassandra-test/bench"
+)
+
+// coordinatorPath is where worker instances POST their Result.
+const coordinatorPath = "/results"
+
+// runCoordinator serves coordinatorPath until cfg.CoordinatorExpect results
+// have been posted (or ctx is done), then prints the combined summary.
+func runCoordinator(ctx context.Context, cfg config) int {
+	var mu sync.Mutex
+	var results []bench.Result
+	done := make(chan struct{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(coordinatorPath, func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "POST a Result JSON document", http.StatusMethodNotAllowed)
+			return
+		}
+		var r bench.Result
+		if err := json.NewDecoder(req.Body).Decode(&r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(results) >= cfg.CoordinatorExpect {
+			http.Error(w, "all expected results already received", http.StatusConflict)
+			return
+		}
+		results = append(results, r)
+		log.Printf("Received result %d of %d from %s.", len(results), cfg.CoordinatorExpect, req.RemoteAddr)
+		if len(results) == cfg.CoordinatorExpect {
+			close(done)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	server := &http.Server{Addr: cfg.CoordinatorListen, Handler: mux}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.ListenAndServe() }()
+	fmt.Printf("Coordinator listening on %s%s for %d results...\n", cfg.CoordinatorListen, coordinatorPath, cfg.CoordinatorExpect)
+
+	// stopped says why the coordinator gave up waiting, if it did.
+	var stopped string
+	exitCode := exitOK
+	select {
+	case <-done:
+	case err := <-serveErr:
+		log.Printf("Coordinator server failed: %v", err)
+		return exitListen
+	case <-ctx.Done():
+		stopped, exitCode = "Stopped by -max-runtime", exitMaxRuntime
+		if errors.Is(context.Cause(ctx), bench.ErrInterrupted) {
+			stopped, exitCode = "Interrupted", exitInterrupt
+		}
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(results) == 0 {
+		log.Printf("%s before any result was received.", stopped)
+		return exitCode
+	}
+	merged := bench.MergeResults(results, log.Default())
+	if stopped != "" {
+		fmt.Printf("\n%s with %d of %d results. Partial combined summary:\n", stopped, len(results), cfg.CoordinatorExpect)
+		printResult(merged, cfg.Output)
+		return exitCode
+	}
+	fmt.Printf("\nCombined summary of %d results:\n", len(results))
+	printResult(merged, cfg.Output)
+	return exitOK
+}
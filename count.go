@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strconv"
+
+	"cassandra-test/bench"
+)
+
+// countFlag is a flag.Value for counts that accepts bench.ParseCount suffixes.
+type countFlag struct {
+	p *int
+}
+
+func newCountFlag(p *int, value int) countFlag {
+	*p = value
+	return countFlag{p}
+}
+
+func (c countFlag) String() string {
+	if c.p == nil {
+		return "0"
+	}
+	return strconv.Itoa(*c.p)
+}
+
+func (c countFlag) Set(s string) error {
+	n, err := bench.ParseCount(s)
+	if err != nil {
+		return err
+	}
+	*c.p = n
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"cassandra-test/bench"
+)
+
+// existenceCheck runs the -existence-check pre-scan and prints what
+// fraction of the sampled keys exists. With -strict it returns
+// exitLowHitRate when even the upper end of the confidence interval is
+// below -min-hit-rate.
+func existenceCheck(ctx context.Context, querier bench.Querier, cfg config) int {
+	r, err := bench.CheckExistence(ctx, querier, cfg.Config, cfg.ExistenceCheck)
+	if err != nil {
+		log.Fatalf("Existence check failed: %v", err)
+	}
+	fmt.Printf("Existence check: %d of %d sampled keys found (%.2f%%, 95%% CI %.2f%%-%.2f%%), %d reads failed\n",
+		r.Found, r.Checked, r.FoundPct, r.Low*100, r.High*100, r.Failed)
+	if r.StoppedEarly {
+		log.Printf("WARNING: the existence check was stopped after %d of %d keys.", r.Checked+r.Failed, r.Sampled)
+	}
+	if r.Checked > 0 && r.High*100 < cfg.MinHitRatePct {
+		log.Printf("WARNING: at most %.2f%% of the keys exist, below -min-hit-rate %.2f%%; the keys file is probably stale or meant for another table.", r.High*100, cfg.MinHitRatePct)
+		if cfg.Strict {
+			return exitLowHitRate
+		}
+	}
+	return exitOK
+}
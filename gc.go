@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime/debug"
+)
+
+// applyGCSettings applies -gogc and -memory-limit-mb before the run.
+//
+// At tens of thousands of queries per second the client allocates fast
+// enough that the default GOGC=100 collects many times a second, and its
+// stop-the-world pauses and assist work land in the measured latencies.
+// Raising -gogc to 200-400 cuts the number of cycles roughly in proportion,
+// at the cost of a larger heap; pairing it with a -memory-limit-mb of about
+// half the host's free memory keeps the heap bounded if the run grows.
+// -gogc -1 with a memory limit collects only when the limit is near, which
+// suits short runs on hosts with memory to spare.
+func applyGCSettings(cfg config) {
+	if cfg.GOGC != 0 {
+		debug.SetGCPercent(cfg.GOGC)
+	}
+	if cfg.MemoryLimitMB > 0 {
+		debug.SetMemoryLimit(int64(cfg.MemoryLimitMB) << 20)
+	}
+}
+
+// gcSettings describes the GC percentage and soft memory limit in effect.
+func gcSettings(cfg config) string {
+	percent := os.Getenv("GOGC")
+	if cfg.GOGC < 0 {
+		percent = "off"
+	} else if cfg.GOGC > 0 {
+		percent = fmt.Sprint(cfg.GOGC)
+	} else if percent == "" {
+		percent = "100"
+	}
+	limit := "none"
+	if l := debug.SetMemoryLimit(-1); l != math.MaxInt64 {
+		limit = fmt.Sprintf("%d MiB", l>>20)
+	}
+	return fmt.Sprintf("GOGC %s, memory limit %s", percent, limit)
+}
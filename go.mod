@@ -2,10 +2,21 @@ module cassandra-test
 
 go 1.24.5
 
-require github.com/gocql/gocql v1.7.0
+require (
+	github.com/HdrHistogram/hdrhistogram-go v1.1.2
+	github.com/gdamore/tcell/v2 v2.13.10
+	github.com/gocql/gocql v1.7.0
+	golang.org/x/net v0.50.0
+	golang.org/x/term v0.40.0
+)
 
 require (
+	github.com/gdamore/encoding v1.0.1 // indirect
 	github.com/golang/snappy v0.0.3 // indirect
 	github.com/hailocab/go-hostpool v0.0.0-20160125115350-e80d13ce29ed // indirect
+	github.com/lucasb-eyer/go-colorful v1.3.0 // indirect
+	github.com/rivo/uniseg v0.4.7 // indirect
+	golang.org/x/sys v0.41.0 // indirect
+	golang.org/x/text v0.34.0 // indirect
 	gopkg.in/inf.v0 v0.9.1 // indirect
 )
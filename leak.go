@@ -0,0 +1,36 @@
+package main
+
+import (
+	"log"
+	"runtime"
+	"time"
+)
+
+// leakSlack is how many goroutines above the baseline are tolerated after
+// shutdown, to allow for runtime and standard library helpers that start
+// lazily (timers, the network poller, signal handling).
+const leakSlack = 2
+
+// leakSettle bounds how long checkGoroutineLeaks waits for goroutines that
+// are still winding down after shutdown.
+const leakSettle = 2 * time.Second
+
+// checkGoroutineLeaks compares the goroutine count after shutdown against
+// the baseline taken before the session was created and logs a warning with
+// a goroutine dump when it didn't return to near the baseline. It is
+// informational only.
+func checkGoroutineLeaks(baseline int) {
+	deadline := time.Now().Add(leakSettle)
+	n := runtime.NumGoroutine()
+	for n > baseline+leakSlack && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		n = runtime.NumGoroutine()
+	}
+	if n <= baseline+leakSlack {
+		log.Printf("Leak check: %d goroutines after shutdown (baseline %d).", n, baseline)
+		return
+	}
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+	log.Printf("WARNING: leak check found %d goroutines after shutdown (baseline %d). Still running:\n%s", n, baseline, buf)
+}
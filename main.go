@@ -1,133 +1,231 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
-	"strconv"
-	"sync"
+	"runtime"
 	"time"
 
-	"github.com/gocql/gocql"
+	"cassandra-test/bench"
 )
 
 // The path to the file where the generated keys are stored.
 const keysFilePath = "query_keys.json"
 
-// QueryKey represents a primary key for a row in the test_table.
-type QueryKey struct {
-	EqpModel     string `json:"eqp_model"`
-	StrategyName string `json:"strtgy_name"`
-	JobID        string `json:"job_id"`
-}
+// Exit codes for runs that complete but should fail a script or CI job.
+const (
+	exitOK         = 0
+	exitPanic      = 2
+	exitLowHitRate = 3
+	exitMaxRuntime = 4
+	exitConnect    = 5
+	exitFailFast   = 6
+	exitSelfTest   = 7
+	exitNotReady   = 8
+	exitInterrupt  = 9
+	exitSchema     = 10
+	exitSLAMissed  = 11
+	exitListen     = 12
+)
+
+// maxRuntimeGrace is how long a run stopped by -max-runtime gets to wind
+// down and print its partial summary before the process is killed outright.
+const maxRuntimeGrace = 10 * time.Second
 
 func main() {
+	os.Exit(run())
+}
+
+// run performs the whole benchmark and returns the process exit code. It is
+// split out of main so deferred cleanup runs before the process exits.
+func run() int {
+	cfg := parseConfig()
+
+	// Written last, after every other output has been flushed.
+	defer syncStdout()
+
+	// An interrupt cancels ctx like -max-runtime does, so the partial
+	// results are still printed and the output files written.
+	ctx, interrupt := context.WithCancelCause(context.Background())
+	defer interrupt(nil)
+	defer handleInterrupts(interrupt)()
+
+	// -max-runtime is a safety net independent of the intended run length:
+	// cancelling ctx stops every mode cleanly, and if that still hangs the
+	// process exits anyway once the grace period is up.
+	if cfg.MaxRuntime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.MaxRuntime)
+		defer cancel()
+		kill := time.AfterFunc(cfg.MaxRuntime+maxRuntimeGrace, func() {
+			restoreTerminal()
+			log.Printf("Run did not stop within %v of -max-runtime; exiting.", maxRuntimeGrace)
+			os.Exit(exitMaxRuntime)
+		})
+		defer kill.Stop()
+	}
+
 	fmt.Println("Starting Go concurrent Cassandra query test...")
 
-	// Get the concurrency level from the command-line argument.
-	if len(os.Args) < 4 {
-		log.Fatalf("Usage: go run . <concurrency_level> <number_of_queries> <keys_file_path>")
-	}
-	concurrency, err := strconv.Atoi(os.Args[1])
-	if err != nil || concurrency <= 0 {
-		log.Fatalf("Invalid concurrency level. Please provide a positive integer.")
-	}
-	numQueries, err := strconv.Atoi(os.Args[2])
-	if err != nil || numQueries <= 0 {
-		log.Fatalf("Invalid number of queries. Please provide a positive integer.")
-	}
-	
-	keysFilePath := os.Args[3]
-	
-
-	// Read the keys from the JSON file.
-	absPath, _ := filepath.Abs(keysFilePath)
-	fmt.Printf("Reading query keys from %s...\n", absPath)
-	file, err := os.ReadFile(keysFilePath)
-	if err != nil {
-		log.Fatalf("Failed to read keys file: %v", err)
-	}
-
-	var allKeys []QueryKey
-	if err := json.Unmarshal(file, &allKeys); err != nil {
-		log.Fatalf("Failed to unmarshal JSON: %v", err)
-	}
-
-	if len(allKeys) == 0 {
-		log.Fatalf("No keys found in the JSON file. Please run the data inserter first.")
-	}
-
-	// --- Cassandra Connection Configuration ---
-	cluster := gocql.NewCluster("127.0.0.1")
-	cluster.Keyspace = "test"
-	cluster.Authenticator = gocql.PasswordAuthenticator{
-		Username: "cassandra",
-		Password: "cassandra",
-	}
-	cluster.Consistency = gocql.Quorum
-	cluster.NumConns = concurrency
-	cluster.Timeout = 30 * time.Second
-
-	session, err := cluster.CreateSession()
-	if err != nil {
-		log.Fatalf("Failed to connect to Cassandra: %v", err)
-	}
-	defer session.Close()
-
-	fmt.Println("Cassandra session established. Preparing statement...")
-
-	fmt.Printf("Executing %d concurrent queries with a concurrency level of %d...\n", numQueries, concurrency)
-
-	var wg sync.WaitGroup
-	var successfulQueries int64
-	var mu sync.Mutex
-
-	startTime := time.Now()
-
-	// Create a channel to send jobs (query IDs) to workers
-	jobs := make(chan int, numQueries)
-
-	// Start a fixed number of worker goroutines
-	for w := 1; w <= concurrency; w++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-			for queryID := range jobs {
-				key := allKeys[queryID%len(allKeys)]
-		
-				iter := session.Query(
-					"SELECT eqp_model FROM test_table WHERE eqp_model = ? AND job_id = ? AND strtgy_name = ?",
-					key.EqpModel, key.JobID, key.StrategyName,
-				).Iter()
-		
-				var dummy string
-				if iter.Scan(&dummy) {
-					mu.Lock()
-					successfulQueries++
-					mu.Unlock()
-				}
-		
-				if err := iter.Close(); err != nil {
-					log.Printf("Query %d failed: %v", queryID, err)
-				}
+	// Pin the scheduler before anything else starts so client-side behavior
+	// is comparable across hosts with different core counts.
+	if cfg.GOMAXPROCS > 0 {
+		runtime.GOMAXPROCS(cfg.GOMAXPROCS)
+	}
+	applyGCSettings(cfg)
+	cfg.printConfig()
+	if cfg.SaveBundle != "" {
+		if err := writeBundle(cfg.SaveBundle, cfg); err != nil {
+			log.Fatalf("Failed to write -save-bundle: %v", err)
+		}
+	}
+
+	if cfg.ConnectOnly {
+		return connectOnly(cfg)
+	}
+	if cfg.CoordinatorListen != "" {
+		return runCoordinator(ctx, cfg)
+	}
+	if cfg.SelfTest {
+		return selfTest(ctx, cfg)
+	}
+	if cfg.ExistenceCheck > 0 {
+		session, err := bench.CreateSession(cfg.Config)
+		if err != nil {
+			log.Fatalf("Failed to connect to Cassandra: %v", err)
+		}
+		defer session.Close()
+		return existenceCheck(ctx, bench.NewQuerier(session), cfg)
+	}
+
+	// Deferred before session.Close so the check runs after it.
+	if cfg.LeakCheck {
+		defer checkGoroutineLeaks(runtime.NumGoroutine())
+	}
+
+	if cfg.TraceSample > 0 {
+		trace, err := os.Create(cfg.TraceFile)
+		if err != nil {
+			log.Fatalf("Failed to create trace file: %v", err)
+		}
+		defer func() {
+			if err := closeOutput(trace); err != nil {
+				log.Printf("Failed to write %s: %v", cfg.TraceFile, err)
 			}
-		}(w)
+		}()
+		cfg.TraceOut = trace
+		fmt.Printf("Writing CQL trace events to %s\n", cfg.TraceFile)
 	}
-	
-	// Submit all the jobs to the channel
-	for i := 0; i < numQueries; i++ {
-		jobs <- i
+
+	result, err := runWithRetries(ctx, cfg)
+	if err != nil && !bench.IsStopCause(err) {
+		log.Fatalf("Benchmark failed: %v", err)
+	}
+	// Posted once the summary is printed, partial or not.
+	if cfg.PostResultURL != "" {
+		defer postResultRow(cfg, result)
+	}
+	// The coordinator waits for every worker, so a partial result is
+	// posted too; its StoppedEarly flag tells the merge.
+	if cfg.CoordinatorURL != "" {
+		defer func() {
+			if err := bench.PostResult(cfg.CoordinatorURL, result); err != nil {
+				log.Printf("Failed to post result to coordinator: %v", err)
+			}
+		}()
 	}
-	close(jobs)
 
-	// Wait for all workers to complete their jobs
-	wg.Wait()
+	writeResultFiles(cfg, result)
 
-	totalTime := time.Since(startTime)
+	if result.StoppedEarly {
+		return stoppedEarly(err, cfg, result)
+	}
 
 	fmt.Println("\nAll queries completed.")
-	fmt.Printf("Total successful queries: %d\n", successfulQueries)
-	fmt.Printf("Total time taken: %.2f seconds\n", totalTime.Seconds())
+	printResult(result, cfg.Output)
+	if result.SLAMissed {
+		return exitSLAMissed
+	}
+
+	// A run where almost nothing matched usually means the keys file does
+	// not belong to this table, not that the table is fast.
+	if result.HitRatePct < cfg.MinHitRatePct {
+		log.Printf("WARNING: hit rate %.2f%% is below %.2f%%.", result.HitRatePct, cfg.MinHitRatePct)
+		if result.FailedQueries == 0 {
+			log.Printf("WARNING: no queries failed, so the keys file is probably stale or meant for another table.")
+		}
+		if cfg.Strict {
+			return exitLowHitRate
+		}
+	}
+	return exitOK
+}
+
+// runWithRetries connects and runs the benchmark. With -run-retries, a run
+// whose first queries all fail is taken to have started before the cluster
+// was ready: the session is closed and the whole run repeated, up to
+// cfg.RunRetries times. Once any query has succeeded the run is never
+// retried, so real failures still surface.
+func runWithRetries(ctx context.Context, cfg config) (bench.Result, error) {
+	for retry := 0; ; retry++ {
+		// --- Cassandra Connection Configuration ---
+		session, err := bench.CreateSession(cfg.Config)
+		if err != nil {
+			log.Fatalf("Failed to connect to Cassandra: %v", err)
+		}
+		fmt.Println("Cassandra session established. Preparing statement...")
+
+		result, err := bench.RunBenchmark(ctx, session, cfg.Config)
+		session.Close()
+		result.RunRetries = retry
+		if !errors.Is(err, bench.ErrNotReady) || retry >= cfg.RunRetries || ctx.Err() != nil {
+			return result, err
+		}
+		log.Printf("%v; retrying the whole run in %v (%d of %d).", err, cfg.RunRetryDelay, retry+1, cfg.RunRetries)
+		select {
+		case <-time.After(cfg.RunRetryDelay):
+		case <-ctx.Done():
+			return result, err
+		}
+	}
+}
+
+// stoppedEarly prints the partial result of a run that was cut off and
+// returns the exit code for why it stopped. err is what bench.RunBenchmark
+// returned.
+func stoppedEarly(err error, cfg config, r bench.Result) int {
+	if errors.Is(err, bench.ErrFailFast) {
+		fmt.Println("\nRun aborted by -fail-fast. Partial results:")
+		printResult(r, cfg.Output)
+		log.Printf("%v", err)
+		return exitFailFast
+	}
+	if errors.Is(err, bench.ErrNotReady) {
+		fmt.Println("\nRun aborted because its first queries all failed. Partial results:")
+		printResult(r, cfg.Output)
+		log.Printf("%v", err)
+		return exitNotReady
+	}
+	if errors.Is(err, bench.ErrSchema) {
+		fmt.Println("\nRun aborted because the table does not match the query. Partial results:")
+		printResult(r, cfg.Output)
+		log.Printf("%v", err)
+		return exitSchema
+	}
+	if errors.Is(err, bench.ErrPanic) {
+		fmt.Println("\nRun aborted by panic. Partial results:")
+		printResult(r, cfg.Output)
+		return exitPanic
+	}
+	if errors.Is(err, bench.ErrInterrupted) {
+		fmt.Println("\nRun interrupted. Partial results:")
+		printResult(r, cfg.Output)
+		return exitInterrupt
+	}
+	fmt.Println("\nRun stopped by -max-runtime. Partial results:")
+	printResult(r, cfg.Output)
+	return exitMaxRuntime
 }
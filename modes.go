@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// modeFlags reports, for every flag that takes part in an exclusion, whether
+// cfg sets it.
+func modeFlags(cfg config) map[string]bool {
+	return map[string]bool{
+		"-chunk-size":           cfg.ChunkSize > 0,
+		"-cold-start":           cfg.ColdStart,
+		"-consistency-mix":      len(cfg.ConsistencyMix) > 0,
+		"-count-only":           cfg.CountOnly,
+		"-cql-trace-sample":     cfg.TraceSample > 0,
+		"-existence-check":      cfg.ExistenceCheck > 0,
+		"-fast-path":            cfg.FastPath,
+		"-in-list":              cfg.InList > 0,
+		"-keys-format csv":      cfg.KeysFormat == "csv",
+		"-map-scan":             cfg.MapScan,
+		"-max-partitions":       cfg.MaxPartitions > 0,
+		"-measure-bytes":        cfg.MeasureBytes,
+		"-metadata-column":      cfg.MetadataColumn != "",
+		"-min-keys":             cfg.MinKeys > 0,
+		"-no-prepare":           cfg.NoPrepare,
+		"-null-key-miss":        cfg.NullKeyMiss,
+		"-partition-only":       cfg.PartitionOnly,
+		"-phases":               len(cfg.Phases) > 0,
+		"-prime":                cfg.Prime,
+		"-print-sample-query":   cfg.PrintSampleQuery,
+		"-rate-schedule":        len(cfg.RateSchedule) > 0,
+		"-repeat":               cfg.Repeat > 1,
+		"-replay":               cfg.Replay,
+		"-self-test":            cfg.SelfTest,
+		"-sla-p99-ms":           cfg.SLAP99Ms > 0,
+		"-split-prepare":        cfg.SplitPrepare > 0,
+		"-stream-keys":          cfg.StreamKeys,
+		"-sweep-consistency":    len(cfg.SweepConsistency) > 0,
+		"-targets":              len(cfg.Targets) > 0,
+		"-validate-json-schema": cfg.ValidateKeys,
+		"-warmup":               cfg.Warmup > 0,
+		"-write-mix":            cfg.WriteMix > 0,
+	}
+}
+
+// exclusions are the modes that cannot be combined with some other flags:
+// the mode, why (if it needs saying), and the flags it excludes. The check
+// and its message are both built from here, so each list is written once.
+var exclusions = []struct {
+	mode     string
+	why      string
+	excludes []string
+}{
+	{"-rate-schedule", "sets the rate itself", []string{"-sla-p99-ms", "-no-prepare", "-repeat", "-replay", "-chunk-size", "-stream-keys"}},
+	{"-phases", "runs its own passes", []string{"-sla-p99-ms", "-rate-schedule", "-no-prepare", "-repeat", "-replay", "-chunk-size", "-stream-keys", "-in-list", "-write-mix", "-fast-path", "-warmup", "-prime", "-targets"}},
+	{"-sweep-consistency", "sets the consistency itself", []string{"-consistency-mix", "-fast-path", "-sla-p99-ms", "-rate-schedule", "-phases", "-no-prepare", "-chunk-size", "-stream-keys"}},
+	{"-fast-path", "sets the consistency itself", []string{"-consistency-mix"}},
+	{"-self-test", "has no Cassandra session", []string{"-cql-trace-sample", "-cold-start"}},
+	{"-chunk-size", "streams the keys", []string{"-replay", "-repeat", "-prime", "-cold-start", "-sla-p99-ms", "-no-prepare", "-validate-json-schema"}},
+	{"-split-prepare", "already runs unprepared queries", []string{"-no-prepare"}},
+	{"-min-keys", "counts the whole keys file up front", []string{"-chunk-size", "-stream-keys"}},
+	{"-metadata-column", "reads one row per key", []string{"-partition-only", "-count-only"}},
+	{"-null-key-miss", "checks eqp_model", []string{"-metadata-column", "-count-only"}},
+	{"-write-mix", "reads its counters back from Cassandra", []string{"-self-test", "-fast-path"}},
+	{"-keys-format csv", "", []string{"-replay", "-chunk-size", "-stream-keys", "-validate-json-schema"}},
+	{"-map-scan", "scans by column name", []string{"-count-only", "-metadata-column", "-null-key-miss", "-measure-bytes"}},
+	{"-print-sample-query", "needs the keys up front", []string{"-chunk-size", "-stream-keys"}},
+	{"-max-partitions", "selects from the whole keys file", []string{"-chunk-size", "-stream-keys"}},
+	{"-existence-check", "reads a sample of the keys file", []string{"-in-list", "-replay", "-chunk-size", "-stream-keys", "-phases", "-write-mix"}},
+	{"-in-list", "groups the whole keys file", []string{"-partition-only", "-metadata-column", "-replay", "-chunk-size", "-stream-keys"}},
+	{"-stream-keys", "reads each key once as it is decoded", []string{"-chunk-size", "-warmup", "-replay", "-repeat", "-prime", "-cold-start", "-sla-p99-ms", "-no-prepare", "-validate-json-schema"}},
+	{"-repeat", "", []string{"-sla-p99-ms", "-no-prepare"}},
+}
+
+// checkExclusions returns an error naming the first mode in exclusions
+// that cfg combines with a flag it excludes.
+func checkExclusions(cfg config) error {
+	set := modeFlags(cfg)
+	for _, e := range exclusions {
+		if !set[e.mode] {
+			continue
+		}
+		for _, f := range e.excludes {
+			if !set[f] {
+				continue
+			}
+			if e.why == "" {
+				return fmt.Errorf("%s cannot be combined with %s.", e.mode, orList(e.excludes))
+			}
+			return fmt.Errorf("%s %s and cannot be combined with %s.", e.mode, e.why, orList(e.excludes))
+		}
+	}
+	return nil
+}
+
+// orList joins flags as "a, b or c".
+func orList(flags []string) string {
+	if len(flags) == 1 {
+		return flags[0]
+	}
+	return strings.Join(flags[:len(flags)-1], ", ") + " or " + flags[len(flags)-1]
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"cassandra-test/bench"
+)
+
+func TestExclusionsNameKnownFlags(t *testing.T) {
+	known := modeFlags(config{})
+	for _, e := range exclusions {
+		for _, f := range append([]string{e.mode}, e.excludes...) {
+			if _, ok := known[f]; !ok {
+				t.Errorf("exclusion of %s names %s, which modeFlags does not know", e.mode, f)
+			}
+		}
+	}
+}
+
+func TestCheckExclusions(t *testing.T) {
+	if err := checkExclusions(config{Config: bench.Config{Repeat: 2, Prime: true}}); err != nil {
+		t.Errorf("-repeat with -prime: %v, want no error", err)
+	}
+	err := checkExclusions(config{Config: bench.Config{Phases: []bench.Phase{{Op: "write", Queries: 10}}, Warmup: 5}})
+	want := "-phases runs its own passes and cannot be combined with -sla-p99-ms, -rate-schedule, -no-prepare, -repeat, -replay, -chunk-size, -stream-keys, -in-list, -write-mix, -fast-path, -warmup, -prime or -targets."
+	if err == nil || err.Error() != want {
+		t.Errorf("-phases with -warmup: %v, want %q", err, want)
+	}
+	if err := checkExclusions(config{Config: bench.Config{SplitPrepare: 0.5, NoPrepare: true}}); err == nil || !strings.HasSuffix(err.Error(), "combined with -no-prepare.") {
+		t.Errorf("-split-prepare with -no-prepare: %v, want an error naming -no-prepare", err)
+	}
+}
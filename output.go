@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"cassandra-test/bench"
+)
+
+// fsyncOutputs makes closeOutput fsync files before closing them (-fsync),
+// so a crash or power loss right after the run cannot truncate them. It is
+// set once from the command line before anything is written.
+var fsyncOutputs bool
+
+// closeOutput closes an output file, fsyncing it first with -fsync.
+func closeOutput(f *os.File) error {
+	if fsyncOutputs {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// writeOutput creates path and fills it with write, fsyncing it with
+// -fsync.
+func writeOutput(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return closeOutput(f)
+}
+
+// writeOutputFile writes data to path like os.WriteFile, fsyncing it with
+// -fsync.
+func writeOutputFile(path string, data []byte) error {
+	return writeOutput(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// syncStdout fsyncs stdout with -fsync when it has been redirected to a
+// file. Terminals and pipes cannot be synced, so the error is ignored.
+func syncStdout() {
+	if fsyncOutputs {
+		os.Stdout.Sync()
+	}
+}
+
+// handleInterrupts cancels the run with bench.ErrInterrupted on the first SIGINT
+// or SIGTERM, so it stops like -max-runtime does and still writes its
+// partial summary and output files. A second signal gets the default
+// behavior and kills the process. The returned func stops listening.
+func handleInterrupts(cancel context.CancelCauseFunc) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			log.Printf("Received %v; stopping and writing partial results (send it again to exit immediately).", sig)
+			cancel(bench.ErrInterrupted)
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// writeResultFiles writes the -hgrm-file and -timeline-file of a run,
+// partial or not, before the summary is printed.
+func writeResultFiles(cfg config, result bench.Result) {
+	if cfg.HgrmFile != "" {
+		if err := writeOutput(cfg.HgrmFile, result.WriteHgrm); err != nil {
+			log.Printf("Failed to write -hgrm-file: %v", err)
+		}
+	}
+	if cfg.TimelineFile != "" {
+		if err := writeOutput(cfg.TimelineFile, result.WriteTimeline); err != nil {
+			log.Printf("Failed to write -timeline-file: %v", err)
+		}
+	}
+}
+
+// printResult writes the summary of r to stdout for the command line.
+func printResult(r bench.Result, format string) {
+	if err := r.Print(os.Stdout, format); err != nil {
+		log.Fatalf("Failed to print result: %v", err)
+	}
+}
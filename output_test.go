@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"cassandra-test/bench"
+)
+
+// TestInterruptedSelfTestWritesFiles interrupts a -self-test run as
+// handleInterrupts does and checks that the -hgrm-file and -timeline-file
+// hold every query the partial run completed.
+func TestInterruptedSelfTestWritesFiles(t *testing.T) {
+	dir := t.TempDir()
+	hgrm := filepath.Join(dir, "latency.hgrm")
+	timeline := filepath.Join(dir, "timeline.csv")
+	cfg := parseArgs(t, "-self-test", "-self-test-error-rate", "0", "-hgrm-file", hgrm, "-timeline-file", timeline, "4", "1m")
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	stop := time.AfterFunc(1500*time.Millisecond, func() { cancel(bench.ErrInterrupted) })
+	defer stop.Stop()
+	if code := selfTest(ctx, cfg); code != exitInterrupt {
+		t.Fatalf("selfTest = %d, want exitInterrupt (%d)", code, exitInterrupt)
+	}
+
+	h, err := os.ReadFile(hgrm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := regexp.MustCompile(`#\[Max += +[^,]+, Total count += +(\d+)\]\n#\[Buckets += .*\]\n$`).FindSubmatch(h)
+	if m == nil {
+		t.Fatalf("-hgrm-file does not end with the histogram footer:\n%s", h)
+	}
+	total, _ := strconv.ParseInt(string(m[1]), 10, 64)
+	if total == 0 {
+		t.Fatal("-hgrm-file records no queries")
+	}
+
+	tl, err := os.ReadFile(timeline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(tl), "\n") {
+		t.Fatalf("-timeline-file does not end with a newline:\n%s", tl)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(tl), "\n"), "\n")
+	if lines[0] != "second,qps,errors,inflight" || len(lines) < 3 {
+		t.Fatalf("-timeline-file has no header or fewer than two seconds:\n%s", tl)
+	}
+	var completed int64
+	for i, line := range lines[1:] {
+		fields := strings.Split(line, ",")
+		if len(fields) != 4 || fields[0] != strconv.Itoa(i) {
+			t.Fatalf("-timeline-file row %d is %q, want second %d and three counters", i, line, i)
+		}
+		n, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			t.Fatalf("-timeline-file row %d: %v", i, err)
+		}
+		completed += n
+	}
+	if completed != total {
+		t.Errorf("-timeline-file counts %d completions, -hgrm-file %d", completed, total)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"cassandra-test/bench"
+)
+
+// resultRow flattens the key metrics of r into one row for
+// -post-result-url, so a spreadsheet webhook can append it as is. runID
+// tells the rows of separate runs apart.
+func resultRow(r bench.Result, runID string) map[string]any {
+	host, _ := os.Hostname()
+	return map[string]any{
+		"run_id":            runID,
+		"timestamp":         time.Now().UTC().Format(time.RFC3339),
+		"host":              host,
+		"tag":               r.Tag,
+		"concurrency":       r.Concurrency,
+		"queries":           r.Queries,
+		"completed":         r.CompletedQueries,
+		"successful":        r.SuccessfulQueries,
+		"failed":            r.FailedQueries,
+		"hit_rate_pct":      r.HitRatePct,
+		"deadline_exceeded": r.DeadlineExceeded,
+		"server_timeouts":   r.ServerTimeouts,
+		"qps":               r.QPS,
+		"avg_latency_ms":    r.AvgLatencyMs,
+		"p50_ms":            r.P50Ms,
+		"p90_ms":            r.P90Ms,
+		"p99_ms":            r.P99Ms,
+		"max_ms":            r.MaxMs,
+		"total_seconds":     r.TotalSeconds,
+		"stopped_early":     r.StoppedEarly,
+	}
+}
+
+// postResultRow sends the final result as one flat row to
+// -post-result-url, as JSON or as a form, retrying failed attempts
+// -post-result-retries times. Unlike -webhook-url it fires once, after the
+// run. A failure is logged and does not change the exit code.
+func postResultRow(cfg config, r bench.Result) {
+	runID := strconv.FormatInt(time.Now().UnixNano(), 36)
+	row := resultRow(r, runID)
+	var body []byte
+	contentType := "application/json"
+	if cfg.PostResultFormat == "form" {
+		form := url.Values{}
+		for k, v := range row {
+			form.Set(k, fmt.Sprint(v))
+		}
+		body = []byte(form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	} else {
+		body, _ = json.Marshal(row)
+	}
+
+	client := &http.Client{Timeout: cfg.PostResultTimeout}
+	for attempt := 0; ; attempt++ {
+		err := postRow(client, cfg.PostResultURL, contentType, body)
+		if err == nil {
+			fmt.Printf("Posted result row %s to -post-result-url\n", runID)
+			return
+		}
+		if attempt >= cfg.PostResultRetries {
+			log.Printf("Failed to post result to -post-result-url after %d attempts: %v", attempt+1, err)
+			return
+		}
+		delay := time.Duration(attempt+1) * time.Second
+		log.Printf("Posting result to -post-result-url failed: %v; retrying in %v.", err, delay)
+		time.Sleep(delay)
+	}
+}
+
+// postRow POSTs body to url and fails on a non-2xx response.
+func postRow(client *http.Client, url, contentType string, body []byte) error {
+	resp, err := client.Post(url, contentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"cassandra-test/bench"
+)
+
+// Defaults for a -self-test run without positional arguments.
+const (
+	selfTestConcurrency = 8
+	selfTestQueries     = 10000
+	selfTestKeys        = 1000
+)
+
+// selfTest runs the whole pipeline (keys file, worker pool, stats,
+// percentiles, summary) against a bench.NewFakeQuerier, then checks the
+// summary against what was injected. Without a keys file it writes
+// synthetic keys to a temporary one first.
+func selfTest(ctx context.Context, cfg config) int {
+	if cfg.KeysFilePath == "" {
+		path, err := writeSelfTestKeys(selfTestKeys)
+		if err != nil {
+			log.Fatalf("Failed to write self-test keys: %v", err)
+		}
+		defer os.Remove(path)
+		cfg.KeysFilePath = path
+	}
+
+	fmt.Printf("Self-test against an in-memory fake: latency %v ± %v, error rate %.2f%%, miss rate %.2f%%\n",
+		cfg.SelfTestLatency, cfg.SelfTestJitter, cfg.SelfTestErrorRate*100, cfg.SelfTestMissRate*100)
+	fake := bench.NewFakeQuerier(cfg.SelfTestLatency, cfg.SelfTestJitter, cfg.SelfTestErrorRate, cfg.SelfTestMissRate, cfg.Seed)
+	if cfg.ExistenceCheck > 0 {
+		return existenceCheck(ctx, fake, cfg)
+	}
+	result, err := bench.Run(ctx, fake, cfg.Config)
+	if err != nil && !bench.IsStopCause(err) {
+		log.Fatalf("Self-test failed: %v", err)
+	}
+	if cfg.PostResultURL != "" {
+		defer postResultRow(cfg, result)
+	}
+	writeResultFiles(cfg, result)
+	if result.StoppedEarly {
+		return stoppedEarly(err, cfg, result)
+	}
+	fmt.Println("\nAll queries completed.")
+	printResult(result, cfg.Output)
+
+	problems := checkSelfTest(cfg, result)
+	for _, p := range problems {
+		log.Printf("SELF-TEST FAILED: %s", p)
+	}
+	if len(problems) > 0 {
+		return exitSelfTest
+	}
+	if result.SLAMissed {
+		return exitSLAMissed
+	}
+	fmt.Println("Self-test passed.")
+	return exitOK
+}
+
+// checkSelfTest compares a self-test summary with the injected behavior.
+// Rate checks only apply when enough events were expected to make a zero
+// count implausible.
+func checkSelfTest(cfg config, r bench.Result) []string {
+	var problems []string
+	// r.Queries rather than -queries, since -stream-keys stops at the end of
+	// the keys file.
+	n := float64(r.Queries)
+	if r.CompletedQueries != int64(r.Queries) {
+		problems = append(problems, fmt.Sprintf("completed %d of %d queries", r.CompletedQueries, r.Queries))
+	}
+	if r.SuccessfulQueries+r.FailedQueries > r.CompletedQueries {
+		problems = append(problems, "successful plus failed queries exceed completed queries")
+	}
+	if cfg.SelfTestErrorRate == 0 && r.FailedQueries > 0 && cfg.OpTimeout == 0 {
+		problems = append(problems, fmt.Sprintf("%d failures without error injection", r.FailedQueries))
+	}
+	if cfg.SelfTestErrorRate*n >= 20 && r.ServerTimeouts == 0 {
+		problems = append(problems, "injected read timeouts were not counted as server timeouts")
+	}
+	if cfg.SelfTestErrorRate*n >= 20 && r.FailedQueries <= r.ServerTimeouts {
+		problems = append(problems, "injected generic errors were not counted as failures")
+	}
+	if cfg.SelfTestMissRate*n >= 20 && r.SuccessfulQueries+r.FailedQueries == r.CompletedQueries {
+		problems = append(problems, "injected misses were not reflected in the hit rate")
+	}
+	if !(r.P50Ms <= r.P90Ms && r.P90Ms <= r.P99Ms && r.P99Ms <= r.MaxMs) {
+		problems = append(problems, fmt.Sprintf("percentiles out of order: p50 %.3f, p90 %.3f, p99 %.3f, max %.3f", r.P50Ms, r.P90Ms, r.P99Ms, r.MaxMs))
+	}
+	if floor := float64(cfg.SelfTestLatency-cfg.SelfTestJitter) / float64(time.Millisecond); r.P50Ms < floor*0.9 {
+		problems = append(problems, fmt.Sprintf("p50 %.3f ms is below the injected minimum latency %.3f ms", r.P50Ms, floor))
+	}
+	return problems
+}
+
+// writeSelfTestKeys writes n synthetic keys as NDJSON to a temporary file.
+func writeSelfTestKeys(n int) (string, error) {
+	f, err := os.CreateTemp("", "self-test-keys-*.json")
+	if err != nil {
+		return "", err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for i := 0; i < n; i++ {
+		key := bench.QueryKey{
+			EqpModel:     fmt.Sprintf("model-%d", i%50),
+			JobID:        fmt.Sprintf("job-%d", i),
+			StrategyName: fmt.Sprintf("strategy-%d", i%7),
+		}
+		if err := enc.Encode(key); err != nil {
+			f.Close()
+			return "", err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"golang.org/x/term"
+
+	"cassandra-test/bench"
+)
+
+// dashboardHistory is how many per-interval throughput samples the
+// sparkline shows.
+const dashboardHistory = 60
+
+// sparkLevels are the bar glyphs of the throughput sparkline, lowest first.
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// activeDashboard is the -tui dashboard while it is up, so a path that
+// exits the process mid-run can restore the terminal first.
+var activeDashboard atomic.Pointer[dashboard]
+
+// dashboard is the -tui bench.Display. It renders frames from the progress
+// snapshots on a tcell screen, which draws on the terminal's alternate screen so the final
+// summary prints to the normal screen as usual. Throughput is measured
+// between frames; latency and error rate cover the pass so far.
+type dashboard struct {
+	screen      tcell.Screen
+	closeOnce   sync.Once
+	mu          sync.Mutex // guards the fields below
+	started     time.Time
+	last        time.Time
+	lastDone    int64
+	qps         []float64
+	concurrency int
+	frame       []string
+	interrupts  int
+}
+
+// newDashboard takes over screen, typically from tcell.NewScreen, and
+// starts handling its resize and key events.
+func newDashboard(screen tcell.Screen, concurrency int) (*dashboard, error) {
+	if err := screen.Init(); err != nil {
+		return nil, err
+	}
+	screen.HideCursor()
+	now := time.Now()
+	d := &dashboard{screen: screen, started: now, last: now, concurrency: concurrency}
+	activeDashboard.Store(d)
+	go d.events()
+	return d, nil
+}
+
+// events redraws the last frame when the terminal is resized and forwards
+// Ctrl-C, which the screen's raw mode turns into a key press, as SIGINT so
+// it stops the run as it would without -tui. It returns once the screen is
+// closed.
+func (d *dashboard) events() {
+	for {
+		switch ev := d.screen.PollEvent().(type) {
+		case nil:
+			return
+		case *tcell.EventResize:
+			d.mu.Lock()
+			d.draw()
+			d.mu.Unlock()
+		case *tcell.EventKey:
+			if ev.Key() != tcell.KeyCtrlC {
+				continue
+			}
+			d.mu.Lock()
+			d.interrupts++
+			second := d.interrupts > 1
+			d.mu.Unlock()
+			// The second one exits the process, so leave the screen first.
+			if second {
+				d.Close()
+			}
+			if p, err := os.FindProcess(os.Getpid()); err == nil {
+				p.Signal(os.Interrupt)
+			}
+		}
+	}
+}
+
+// Render draws one frame for the snapshot r.
+func (d *dashboard) Render(r bench.Result) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	qps := 0.0
+	if dt := now.Sub(d.last).Seconds(); dt > 0 {
+		qps = float64(r.CompletedQueries-d.lastDone) / dt
+	}
+	d.last, d.lastDone = now, r.CompletedQueries
+	d.qps = append(d.qps, qps)
+	if len(d.qps) > dashboardHistory {
+		d.qps = d.qps[len(d.qps)-dashboardHistory:]
+	}
+
+	title := "cassandra-test"
+	if r.Tag != "" {
+		title += " [" + r.Tag + "]"
+	}
+	done := 0.0
+	if r.Queries > 0 {
+		done = float64(r.CompletedQueries) / float64(r.Queries)
+	}
+	d.frame = []string{
+		fmt.Sprintf("%s    elapsed %v", title, now.Sub(d.started).Round(time.Second)),
+		"",
+		fmt.Sprintf("  Progress   %s %5.1f%%  %d of %d", bench.ProgressBar(done, 30), done*100, r.CompletedQueries, r.Queries),
+		fmt.Sprintf("  QPS        %10.1f/s  (pass avg %.1f/s)", qps, r.QPS),
+		fmt.Sprintf("             %s", sparkline(d.qps)),
+		fmt.Sprintf("  p99        %10.3f ms  (p50 %.3f ms, max %.3f ms)", r.P99Ms, r.P50Ms, r.MaxMs),
+		fmt.Sprintf("  Errors     %10.2f%%   (%d failed, %d client timeouts)",
+			errorRate(r), r.FailedQueries, r.DeadlineExceeded),
+		fmt.Sprintf("  Hit rate   %10.2f%%", r.HitRatePct),
+		fmt.Sprintf("  In-flight  %10.0f   of %d", r.AvgInFlight, d.concurrency),
+		"",
+		"  Ctrl-C stops the run and prints the partial summary.",
+	}
+	d.draw()
+}
+
+// draw repaints the last frame, clipped to the screen. It repaints every
+// cell so a log line written over the dashboard does not linger. d.mu must
+// be held.
+func (d *dashboard) draw() {
+	d.screen.Clear()
+	for y, line := range d.frame {
+		x := 0
+		for _, r := range line {
+			d.screen.SetContent(x, y, r, nil, tcell.StyleDefault)
+			x++
+		}
+	}
+	d.screen.Sync()
+}
+
+// Close restores the terminal. It may be called more than once.
+func (d *dashboard) Close() {
+	d.closeOnce.Do(func() {
+		activeDashboard.CompareAndSwap(d, nil)
+		d.screen.Fini()
+	})
+}
+
+// restoreTerminal closes the -tui dashboard, if one is up, before the
+// process exits mid-run.
+func restoreTerminal() {
+	if d := activeDashboard.Load(); d != nil {
+		d.Close()
+	}
+}
+
+// sparkline renders values scaled to their maximum.
+func sparkline(values []float64) string {
+	var peak float64
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if peak > 0 {
+			i = int(v / peak * float64(len(sparkLevels)-1))
+		}
+		b.WriteRune(sparkLevels[i])
+	}
+	return b.String()
+}
+
+// errorRate returns the percentage of the completed queries of r that
+// failed.
+func errorRate(r bench.Result) float64 {
+	if r.CompletedQueries == 0 {
+		return 0
+	}
+	return float64(r.FailedQueries) / float64(r.CompletedQueries) * 100
+}
+
+// stdoutIsTerminal reports whether stdout can show the dashboard.
+func stdoutIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"cassandra-test/bench"
+)
+
+// screenLine returns row y of the dashboard's simulation screen as text.
+// It holds d.mu since GetContents shares the cells a redraw writes.
+func screenLine(d *dashboard, y int) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cells, w, h := d.screen.(tcell.SimulationScreen).GetContents()
+	if y >= h {
+		return ""
+	}
+	var b strings.Builder
+	for _, c := range cells[y*w : (y+1)*w] {
+		b.WriteString(string(c.Runes))
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+func TestDashboardRedrawsOnResize(t *testing.T) {
+	screen := tcell.NewSimulationScreen("")
+	d, err := newDashboard(screen, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.Render(bench.Result{Queries: 100, CompletedQueries: 50, P99Ms: 1.5})
+	if got := screenLine(d, 0); !strings.HasPrefix(got, "cassandra-test") {
+		t.Fatalf("first line = %q, want the title", got)
+	}
+	const inFlightRow = 8
+	if got := screenLine(d, inFlightRow); !strings.Contains(got, "of 4") {
+		t.Fatalf("in-flight line = %q, want the concurrency", got)
+	}
+
+	// Shrinking drops the lower rows; growing back leaves them blank until
+	// the resize event redraws the frame.
+	screen.SetSize(80, 2)
+	screen.SetSize(80, 25)
+	if err := screen.PostEvent(tcell.NewEventResize(80, 25)); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(screenLine(d, inFlightRow), "of 4") {
+		if time.Now().After(deadline) {
+			t.Fatalf("in-flight line after a resize = %q, want it redrawn", screenLine(d, inFlightRow))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	d.Close()
+	if activeDashboard.Load() != nil {
+		t.Error("Close left the dashboard registered for restoreTerminal")
+	}
+}